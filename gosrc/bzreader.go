@@ -6,37 +6,173 @@ package bzreader
 
 import (
 	"bufio"
+	"bytes"
+	"bzsplit"
 	"compress/bzip2"
 	"fmt"
 	"os"
 	"io"
 )
 
+//
+// A ChunkSource hands out the bzip2 compressed chunks of a dump by index,
+// starting at 1.
+//
+type ChunkSource interface {
+	OpenChunk(index int) (io.ReadCloser, os.Error)
+	Chunks() int
+}
+
+//
+// Chunks stored as rec<index>dbname.xml.bz2 files in a directory.
+//
+type SplitSource struct {
+	Path   string
+	Dbname string
+}
+
+func (ss *SplitSource) chunkFile(index int) string {
+	return fmt.Sprintf("%v/rec%05d%v", ss.Path, index, ss.Dbname)
+}
+
+func (ss *SplitSource) Chunks() int {
+	count := 0
+	for {
+		if _, err := os.Stat(ss.chunkFile(count + 1)); err != nil {
+			return count
+		}
+		count++
+	}
+	return count
+}
+
+func (ss *SplitSource) OpenChunk(index int) (io.ReadCloser, os.Error) {
+	cfin, err := os.Open(ss.chunkFile(index))
+	if err != nil {
+		return nil, err
+	}
+	return cfin, nil
+}
+
+type nopCloser struct {
+	io.Reader
+}
+
+func (nopCloser) Close() os.Error {
+	return nil
+}
+
+//
+// Chunks read straight out of the original dump, using a block index
+// from bzsplit.Scan.
+//
+// For multistream dumps, each chunk is a whole bzip2 stream rather than
+// a single block, and the index holds byte aligned stream offsets.
+//
+type BlockSource struct {
+	fin     *os.File
+	blocks  []bzsplit.Block
+	streams bool
+}
+
+func NewBlockSource(dbfile, indexfile string) (*BlockSource, os.Error) {
+	blocks, err := bzsplit.ReadIndex(indexfile)
+	if err != nil {
+		return nil, err
+	}
+	fin, err := os.Open(dbfile)
+	if err != nil {
+		return nil, err
+	}
+	return &BlockSource{fin: fin, blocks: blocks}, nil
+}
+
+func NewStreamSource(dbfile, indexfile string) (*BlockSource, os.Error) {
+	bs, err := NewBlockSource(dbfile, indexfile)
+	if err != nil {
+		return nil, err
+	}
+	bs.streams = true
+	return bs, nil
+}
+
+func (bs *BlockSource) Chunks() int {
+	return len(bs.blocks)
+}
+
+func (bs *BlockSource) OpenChunk(index int) (io.ReadCloser, os.Error) {
+	if index < 1 || index > len(bs.blocks) {
+		return nil, os.ENOENT
+	}
+	if bs.streams {
+		b := bs.blocks[index-1]
+		return nopCloser{io.NewSectionReader(bs.fin, b.Start/8, (b.End-b.Start)/8)}, nil
+	}
+	data, err := bzsplit.ExtractBlock(bs.fin, bs.blocks[index-1])
+	if err != nil {
+		return nil, err
+	}
+	return nopCloser{bytes.NewBuffer(data)}, nil
+}
+
+func (bs *BlockSource) Close() {
+	bs.fin.Close()
+}
+
+//
+// For multistream dumps: The stream the index doesn't mention, in front of
+// the first one it does, as the only chunk of a source of its own. That's
+// where the <siteinfo> is.
+//
+func (bs *BlockSource) Header() ChunkSource {
+	return headerSource{bs}
+}
+
+type headerSource struct {
+	bs *BlockSource
+}
+
+func (hs headerSource) Chunks() int {
+	return 1
+}
+
+func (hs headerSource) OpenChunk(index int) (io.ReadCloser, os.Error) {
+	if index != 1 || !hs.bs.streams || len(hs.bs.blocks) == 0 {
+		return nil, os.ENOENT
+	}
+	return nopCloser{io.NewSectionReader(hs.bs.fin, 0, hs.bs.blocks[0].Start/8)}, nil
+}
+
 type SegmentedBzReader struct {
-	Index  int
-	bfin   *bufio.Reader
-	cfin   *os.File
-	path   string
-	dbname string
+	Index int
+	bfin  *bufio.Reader
+	cfin  io.ReadCloser
+	src   ChunkSource
 }
 
 //
 // This will sequentially read .bz2 files starting from a given index.
 //
 func NewBzReader(path, dbname string, index int) *SegmentedBzReader {
+	return NewSourceReader(&SplitSource{Path: path, Dbname: dbname}, index)
+}
+
+//
+// This will sequentially read the chunks of src starting from a given index.
+//
+func NewSourceReader(src ChunkSource, index int) *SegmentedBzReader {
 	sbz := new(SegmentedBzReader)
 	sbz.Index = index
 	sbz.bfin = nil
 	sbz.cfin = nil
-	sbz.path = path
-	sbz.dbname = dbname
+	sbz.src = src
 
 	sbz.OpenNext()
 	return sbz
 }
 
 //
-// Open rec<index>dbname.xml.bz2 for reading
+// Open chunk <index> for reading
 //
 func (sbz *SegmentedBzReader) OpenNext() {
 	if sbz.cfin != nil {
@@ -44,8 +180,7 @@ func (sbz *SegmentedBzReader) OpenNext() {
 		sbz.cfin = nil
 		sbz.bfin = nil
 	}
-	fn := fmt.Sprintf("%v/rec%05d%v", sbz.path, sbz.Index, sbz.dbname)
-	cfin, err := os.Open(fn)
+	cfin, err := sbz.src.OpenChunk(sbz.Index)
 	if err != nil {
 		sbz.cfin = nil
 		sbz.bfin = nil
@@ -106,13 +241,38 @@ func (sbz *SegmentedBzReader) ReadBytes() ([]byte, os.Error) {
 	return nil, os.EOF
 }
 
+//
+// Throw away the next n bytes of the current chunk, for when we already know
+// how far into it we want to be.
+//
+func (sbz *SegmentedBzReader) Skip(n int64) os.Error {
+	if sbz.bfin == nil {
+		return os.EOF
+	}
+	buff := make([]byte, 32*1024)
+	for n > 0 {
+		want := int64(len(buff))
+		if n < want {
+			want = n
+		}
+		got, err := sbz.bfin.Read(buff[:want])
+		n -= int64(got)
+		if err != nil && n > 0 {
+			return err
+		}
+	}
+	return nil
+}
+
 func (sbz *SegmentedBzReader) ReadString() (string, os.Error) {
 	s, e := sbz.ReadBytes()
 	return string(s), e
 }
 
 func (sbz *SegmentedBzReader) Close() {
-	sbz.cfin.Close()
+	if sbz.cfin != nil {
+		sbz.cfin.Close()
+	}
 	sbz.cfin = nil
 	sbz.bfin = nil
 }
@@ -0,0 +1,392 @@
+// bzsplit.go
+//
+// Uses: Splitting a big .bz2 file into one small .bz2 file per compressed
+// block, the same way bzip2recover does, so that we don't need an external
+// program to do it for us.
+//
+// Alternately, just finding where each block is (Scan), so that a single
+// block can later be pulled out of the original file (ExtractBlock) without
+// keeping a second copy of the whole thing on disk.
+//
+// A bzip2 stream is a 4 byte header ("BZh" plus a block size digit)
+// followed by any number of blocks, each starting with the 48-bit block
+// magic, and is ended by the 48-bit end of stream magic and a 32-bit CRC.
+// Blocks are not byte aligned, so we have to look for the magic one bit at
+// a time.
+
+package bzsplit
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const blockMagic = 0x314159265359
+const eosMagic = 0x177245385090
+const magicMask = 0xffffffffffff
+
+type Block struct {
+	// Index of the block, starting at 1, as used in rec#####dbname.
+	Index int
+	// Bit offsets of the start of the block magic and just past the last bit
+	// of the block in the original file.
+	Start, End int64
+	// The block CRC, stored just after the block magic.
+	CRC uint32
+}
+
+type bitWriter struct {
+	bout  *bufio.Writer
+	buff  byte
+	count uint
+}
+
+func newBitWriter(out io.Writer) *bitWriter {
+	return &bitWriter{bout: bufio.NewWriter(out)}
+}
+
+func (bw *bitWriter) WriteBit(bit uint) {
+	bw.buff = bw.buff<<1 | byte(bit&1)
+	bw.count++
+	if bw.count == 8 {
+		bw.bout.WriteByte(bw.buff)
+		bw.buff = 0
+		bw.count = 0
+	}
+}
+
+// Write the lowest n bits of val, most significant first.
+func (bw *bitWriter) WriteBits(n uint, val uint64) {
+	for ; n > 0; n-- {
+		bw.WriteBit(uint(val >> (n - 1)))
+	}
+}
+
+// Write whole bytes, straight through if we're at a byte boundary.
+func (bw *bitWriter) WriteBytes(p []byte) {
+	if bw.count == 0 {
+		bw.bout.Write(p)
+		return
+	}
+	for _, b := range p {
+		bw.WriteBits(8, uint64(b))
+	}
+}
+
+// Pad out the last byte with zeroes and flush everything to disk.
+func (bw *bitWriter) Flush() os.Error {
+	for bw.count != 0 {
+		bw.WriteBit(0)
+	}
+	return bw.bout.Flush()
+}
+
+// A bzip2 header. We always claim the largest block size, since we don't
+// know what the original was and it costs nothing when decompressing.
+func writeHeader(bw *bitWriter) {
+	bw.WriteBits(8, 'B')
+	bw.WriteBits(8, 'Z')
+	bw.WriteBits(8, 'h')
+	bw.WriteBits(8, '9')
+}
+
+// End of stream. With only one block in the stream, the combined stream CRC
+// is just the block CRC.
+func writeTrailer(bw *bitWriter, crc uint32) os.Error {
+	bw.WriteBits(48, eosMagic)
+	bw.WriteBits(32, uint64(crc))
+	return bw.Flush()
+}
+
+// Start a rec#####dbname file: A bzip2 header, then the block magic.
+func openBlock(dir, dbname string, index int) (*os.File, *bitWriter, os.Error) {
+	fn := filepath.Join(dir, fmt.Sprintf("rec%05d%v", index, dbname))
+	fout, err := os.OpenFile(fn, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return nil, nil, err
+	}
+	bw := newBitWriter(fout)
+	writeHeader(bw)
+	bw.WriteBits(48, blockMagic)
+	return fout, bw, nil
+}
+
+// End a rec#####dbname file.
+func closeBlock(fout *os.File, bw *bitWriter, crc uint32) os.Error {
+	err := writeTrailer(bw, crc)
+	fout.Close()
+	return err
+}
+
+//
+// Split the .bz2 file at src into dir/rec#####dbname files, one per block,
+// numbered from 1. If fn is not nil, it is called after each block has been
+// written. Returns the number of blocks written.
+//
+func Split(src, dir, dbname string, fn func(Block)) (int, os.Error) {
+	return walk(src, dir, dbname, Block{}, fn)
+}
+
+//
+// As Split, but pick up where a previous Split left off, after the block
+// given. Returns the total number of blocks, including the earlier ones.
+//
+func SplitFrom(src, dir, dbname string, after Block, fn func(Block)) (int, os.Error) {
+	return walk(src, dir, dbname, after, fn)
+}
+
+//
+// Find all the blocks in the .bz2 file at src without writing anything.
+// fn is called for each block found. Returns the number of blocks.
+//
+func Scan(src string, fn func(Block)) (int, os.Error) {
+	return walk(src, "", "", Block{}, fn)
+}
+
+//
+// As Scan, but pick up where a previous Scan left off, after the block
+// given.
+//
+func ScanFrom(src string, after Block, fn func(Block)) (int, os.Error) {
+	return walk(src, "", "", after, fn)
+}
+
+// Walk through src one bit at a time, looking for blocks, starting right
+// after the given block. If dir is not empty, each block is also written out
+// as a rec file.
+func walk(src, dir, dbname string, after Block, fn func(Block)) (int, os.Error) {
+	fin, err := os.Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer fin.Close()
+
+	if after.End > 0 {
+		if _, err = fin.Seek(after.End/8, 0); err != nil {
+			return after.Index, err
+		}
+	}
+
+	bin := bufio.NewReader(fin)
+
+	var fout *os.File
+	var bw *bitWriter
+	inBlock := false
+
+	// reg holds the most recently read bits. The lowest 48 are compared
+	// against the magic numbers, and bit 48 is the one that just fell out of
+	// that window: If it belongs to a block, it gets written out.
+	var reg uint64
+	var pending uint
+
+	pos := after.End
+	var cur Block
+	var crcLeft uint
+	count := after.Index
+
+	// The first byte may be partly the previous block's.
+	top := 7 - int(pos%8)
+
+	finish := func(end int64) os.Error {
+		cur.End = end
+		inBlock = false
+		if bw != nil {
+			err := closeBlock(fout, bw, cur.CRC)
+			fout = nil
+			bw = nil
+			if err != nil {
+				return err
+			}
+		}
+		count++
+		if fn != nil {
+			fn(cur)
+		}
+		return nil
+	}
+
+	for {
+		b, err := bin.ReadByte()
+		if err == os.EOF {
+			break
+		}
+		if err != nil {
+			return count, err
+		}
+		for i := top; i >= 0; i-- {
+			bit := uint64(b>>uint(i)) & 1
+			reg = reg<<1 | bit
+			pos++
+
+			if pending == 48 {
+				if bw != nil {
+					bw.WriteBit(uint(reg >> 48))
+				}
+			} else {
+				pending++
+			}
+
+			if crcLeft > 0 {
+				cur.CRC = cur.CRC<<1 | uint32(bit)
+				crcLeft--
+			}
+
+			switch reg & magicMask {
+			case blockMagic:
+				if inBlock {
+					if err = finish(pos - 48); err != nil {
+						return count, err
+					}
+				}
+				cur = Block{Index: count + 1, Start: pos - 48}
+				inBlock = true
+				crcLeft = 32
+				pending = 0
+				if dir != "" {
+					fout, bw, err = openBlock(dir, dbname, cur.Index)
+					if err != nil {
+						return count, err
+					}
+				}
+			case eosMagic:
+				if inBlock {
+					if err = finish(pos - 48); err != nil {
+						return count, err
+					}
+				}
+				crcLeft = 0
+				pending = 0
+			}
+		}
+		top = 7
+	}
+
+	// A truncated file: Keep what we have of the last block, like
+	// bzip2recover does.
+	if inBlock {
+		if bw != nil {
+			bw.WriteBits(pending, reg)
+		}
+		if err = finish(pos); err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+//
+// Build a standalone .bz2 stream out of the given block of fin, which must be
+// the same file the block was found in by Scan.
+//
+func ExtractBlock(fin io.ReaderAt, b Block) ([]byte, os.Error) {
+	first := b.Start / 8
+	last := (b.End + 7) / 8
+	raw := make([]byte, last-first)
+	n, err := fin.ReadAt(raw, first)
+	if err != nil && !(err == os.EOF && int64(n) == last-first) {
+		return nil, err
+	}
+
+	out := bytes.NewBuffer(nil)
+	bw := newBitWriter(out)
+	writeHeader(bw)
+
+	// Everything from the block magic on is copied verbatim, shifted into
+	// place a byte at a time, and then whatever bits are left over. The CRC
+	// we need for the trailer is the 32 bits right after the magic.
+	if b.End-b.Start < 80 {
+		return nil, fmt.Errorf("Block %d is only %d bits long", b.Index, b.End-b.Start)
+	}
+	shift := uint(b.Start - first*8)
+	block := make([]byte, (b.End-b.Start)/8)
+	for i := range block {
+		block[i] = raw[i] << shift
+		if shift > 0 {
+			block[i] |= raw[i+1] >> (8 - shift)
+		}
+	}
+	bw.WriteBytes(block)
+	for pos := b.Start + int64(len(block))*8; pos < b.End; pos++ {
+		off := pos - first*8
+		bw.WriteBit(uint(raw[off/8]>>(7-uint(off%8))) & 1)
+	}
+	crc := uint32(block[6])<<24 | uint32(block[7])<<16 | uint32(block[8])<<8 | uint32(block[9])
+
+	err = writeTrailer(bw, crc)
+	if err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+////// Block index file format:
+// <start> <end>
+// One line per block, in order, with the bit offsets of each.
+
+//
+// Write the block index for Scan's results to fn.
+//
+func WriteIndex(fn string, blocks []Block) os.Error {
+	fout, err := os.OpenFile(fn, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	defer fout.Close()
+
+	bout := bufio.NewWriter(fout)
+	for _, b := range blocks {
+		WriteIndexLine(bout, b)
+	}
+	return bout.Flush()
+}
+
+//
+// Write a single block's line of the block index, for those building it
+// up as they go.
+//
+func WriteIndexLine(w io.Writer, b Block) {
+	fmt.Fprintf(w, "%d %d\n", b.Start, b.End)
+}
+
+//
+// Read a block index written by WriteIndex.
+//
+func ReadIndex(fn string) ([]Block, os.Error) {
+	fin, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer fin.Close()
+
+	bin := bufio.NewReader(fin)
+	blocks := []Block{}
+	for {
+		line, err := bin.ReadString('\n')
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			b := Block{Index: len(blocks) + 1}
+			var perr os.Error
+			b.Start, perr = strconv.Atoi64(fields[0])
+			if perr == nil {
+				b.End, perr = strconv.Atoi64(fields[1])
+			}
+			if perr != nil {
+				return nil, fmt.Errorf("%v: line %d: %v", fn, b.Index, perr)
+			}
+			blocks = append(blocks, b)
+		}
+		if err == os.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return blocks, nil
+}
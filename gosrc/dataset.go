@@ -0,0 +1,2114 @@
+// dataset.go
+//
+// Uses: Reading the title cache and chunks bzwikipedia builds in pdata/, for
+// anything that wants at the pages without going through the web server.
+//
+// The functions of note in here are:
+//
+// Open(datfile string) (*Dataset, os.Error)
+//   Open the dataset pdata/bzwikipedia.dat says is being served, say.
+//   OpenWith takes Options for a setup other than the default one.
+//
+// ds.Lookup(title string) (TitleData, bool)
+// ds.ReadWikitext(title string) (string, os.Error)
+//   Find a page, or read its wikitext, the way /wiki/<title> would.
+//   ReadPage also says which revision of it the dump has.
+//
+// ds.Search(phrase string, opts SearchOptions) []string
+//   Titles containing phrase, ignoring case, accents, spaces and
+//   punctuation, best matches first. SearchFacets also says how many there
+//   are in each namespace, and SearchHits how each one scored. With a
+//   trigram index, only the titles it points to are looked at, rather than
+//   all of them, unless it points to too many.
+//
+// ds.SearchText(query string, opts SearchOptions) ([]string, bool)
+//   Titles of the pages that say what query does, if there's a text index,
+//   and whether that's all of them.
+//
+// ds.SearchFuzzy(phrase string, opts SearchOptions) []Hit
+//   Titles with something close to phrase in them, for when it's been
+//   misspelt, if there's a trigram index.
+//
+// ds.Titles() *Cursor
+//   Walk through every title, in order.
+
+package dataset
+
+import (
+	"bufio"
+	"bytes"
+	"bzreader"
+	"confparse"
+	"fmt"
+	"http"
+	"io"
+	"loadfile"
+	"os"
+	"path/filepath"
+	"rand"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"textindex"
+	"textnorm"
+	"unicode"
+	"utf8"
+)
+
+// The title cache version this package reads and writes.
+const Version = 7
+
+// The first version whose records have their search keys.
+const keyedVersion = 7
+
+const TITLE_DELIM = '\n'
+const RECORD_DELIM = '\x02'
+const FIELD_DELIM = '\x03'
+const PREFIX_DELIM = '\x04'
+const KEY_DELIM = '\x05'
+
+//
+// Where to find the files of a dataset, and how to read them.
+//
+type Options struct {
+	// Where the files of datasets from before generation directories are.
+	// Inside a generation directory, the files have the same names.
+	DataDir, TitleFile, TitleIndexFile, BlockFile, TextIndexFile, TrigramFile string
+	// Map the title cache into memory rather than reading it in.
+	Mmap bool
+	// How many goroutines to search with.
+	SearchRoutines int
+	// Say how the title cache is being read.
+	Verbose bool
+}
+
+// The defaults, as in bzwikipedia.conf.
+var DefaultOptions = Options{
+	DataDir:        "pdata",
+	TitleFile:      "pdata/titlecache.dat",
+	TitleIndexFile: "pdata/titleindex.dat",
+	BlockFile:      "pdata/blockindex.dat",
+	TextIndexFile:  "pdata/textindex.dat",
+	TrigramFile:    "pdata/trigrams.dat",
+	Mmap:           true,
+	SearchRoutines: 4,
+	Verbose:        true,
+}
+
+//
+// Where a file of the dataset described by the dat file d is: path itself
+// for datasets from before generation directories, or the file by the same
+// name in its generation directory.
+//
+func FilePath(d map[string]string, path string) string {
+	if d["gendir"] == "" {
+		return path
+	}
+	return filepath.Join(d["gendir"], filepath.Base(path))
+}
+
+// Where the rec files of the dataset described by d live.
+func ChunkDir(d map[string]string, dataDir string) string {
+	if d["gendir"] == "" {
+		return dataDir
+	}
+	return d["gendir"]
+}
+
+//
+// Return the storage_type a dat file was generated with. Anything older
+// than the storage key is split.
+//
+func StorageType(d map[string]string) string {
+	if d["storage"] == "" {
+		return "split"
+	}
+	return d["storage"]
+}
+
+//
+// The title_format a dataset was built with. Those from before there was a
+// choice are plain.
+//
+func Format(d map[string]string) string {
+	if d["format"] == "" {
+		return "plain"
+	}
+	return d["format"]
+}
+
+type searchRange struct{ Start, End int64 }
+
+//
+// Open the chunks described by a dat file, wherever they are stored.
+//
+func OpenChunks(d map[string]string, opts Options) (bzreader.ChunkSource, os.Error) {
+	switch StorageType(d) {
+	case "index":
+		src, err := bzreader.NewBlockSource(d["dbpath"], FilePath(d, opts.BlockFile))
+		if err != nil {
+			return nil, err
+		}
+		return src, nil
+	case "multistream":
+		src, err := bzreader.NewStreamSource(d["dbpath"], FilePath(d, opts.BlockFile))
+		if err != nil {
+			return nil, err
+		}
+		return src, nil
+	}
+	return &bzreader.SplitSource{Path: ChunkDir(d, opts.DataDir), Dbname: d["dbname"]}, nil
+}
+
+func CloseChunks(src bzreader.ChunkSource) {
+	if bs, ok := src.(*bzreader.BlockSource); ok {
+		bs.Close()
+	}
+}
+
+//
+// The titles in the dump's <title> tags are XML escaped, while the
+// multistream index has them raw. ReadTitle looks for the <title> tag, so
+// we keep them escaped.
+//
+func EscapeTitle(title string) string {
+	title = strings.Replace(title, "&", "&amp;", -1)
+	title = strings.Replace(title, "<", "&lt;", -1)
+	title = strings.Replace(title, ">", "&gt;", -1)
+	title = strings.Replace(title, "\"", "&quot;", -1)
+	return title
+}
+
+type TitleData struct {
+	Title string
+	// The chunk the <page> starts in, and how far into the chunk it is
+	// once uncompressed.
+	Start  int
+	Offset int64
+	// The page id, and the number of the namespace the page is in.
+	Id, Ns int
+	// Where the page redirects to, if it's a redirect.
+	Redirect string
+	// How many links to the page the dump has, if they were counted.
+	Links int
+	// What search goes by: textnorm.Key of the title.
+	Key string
+}
+
+//
+// Search results go best first: Highest score, then shortest title, then
+// alphabetically.
+//
+type hitlist []Hit
+
+func (hl hitlist) Len() int {
+	return len(hl)
+}
+func (hl hitlist) Less(a, b int) bool {
+	if hl[a].Score != hl[b].Score {
+		return hl[a].Score > hl[b].Score
+	}
+	x := len(hl[a].Title) - len(hl[b].Title)
+	if x == 0 {
+		return hl[a].Title < hl[b].Title
+	}
+	return x < 0
+}
+func (hl hitlist) Swap(a, b int) {
+	hl[a], hl[b] = hl[b], hl[a]
+}
+func (hl hitlist) Sort() {
+	sort.Sort(hl)
+}
+
+// Write one title cache record.
+func WriteTitleRecord(w io.Writer, td TitleData) {
+	fmt.Fprintf(w, "%c%s", TITLE_DELIM, td.Title)
+	WriteTitleFields(w, td)
+}
+
+// Write everything in a record that comes after the title. The link count
+// goes after the redirect even if there isn't one, and the key after all
+// of them.
+func WriteTitleFields(w io.Writer, td TitleData) {
+	fmt.Fprintf(w, "%c%d%c%d%c%d%c%d", RECORD_DELIM,
+		td.Start, FIELD_DELIM, td.Offset, FIELD_DELIM, td.Id, FIELD_DELIM, td.Ns)
+	if td.Redirect != "" || td.Links != 0 {
+		fmt.Fprintf(w, "%c%s", FIELD_DELIM, td.Redirect)
+	}
+	if td.Links != 0 {
+		fmt.Fprintf(w, "%c%d", FIELD_DELIM, td.Links)
+	}
+	if td.Key != "" {
+		fmt.Fprintf(w, "%c%s", KEY_DELIM, td.Key)
+	}
+}
+
+// How many titles there are in each block of a front coded title cache.
+const FrontBlockSize = 32
+
+//
+// Writes a front coded title cache. Titles are put in blocks of
+// FrontBlockSize. The first one in each block is written out whole, the
+// rest only after however many bytes they have in common with the one
+// before. Where each block starts is kept for the block index.
+//
+type FrontCodedWriter struct {
+	w      io.Writer
+	offset int64
+	count  int
+	last   string
+	Blocks []int64
+}
+
+func NewFrontCodedWriter(w io.Writer) *FrontCodedWriter {
+	return &FrontCodedWriter{w: w}
+}
+
+func (fw *FrontCodedWriter) WriteTitle(td TitleData) {
+	shared := 0
+	if fw.count%FrontBlockSize == 0 {
+		fw.Blocks = append(fw.Blocks, fw.offset)
+	} else {
+		for shared < len(fw.last) && shared < len(td.Title) && fw.last[shared] == td.Title[shared] {
+			shared++
+		}
+	}
+
+	buff := bytes.NewBuffer(nil)
+	fmt.Fprintf(buff, "%c%d%c%s", TITLE_DELIM, shared, PREFIX_DELIM, td.Title[shared:])
+	WriteTitleFields(buff, td)
+	fw.w.Write(buff.Bytes())
+
+	fw.offset += int64(buff.Len())
+	fw.count++
+	fw.last = td.Title
+}
+
+////// Title index file format:
+// <offset>
+// One line per block of a front coded title cache, in order, with the byte
+// offset in the title cache where it starts.
+
+func WriteTitleIndex(fn string, blocks []int64) os.Error {
+	fout, err := os.OpenFile(fn, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	defer fout.Close()
+
+	bout := bufio.NewWriter(fout)
+	for _, offset := range blocks {
+		fmt.Fprintf(bout, "%d\n", offset)
+	}
+	return bout.Flush()
+}
+
+func ReadTitleIndex(fn string) ([]int64, os.Error) {
+	fin, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer fin.Close()
+
+	bin := bufio.NewReader(fin)
+	blocks := []int64{}
+	for {
+		line, err := bin.ReadString('\n')
+		if line = strings.TrimSpace(line); line != "" {
+			offset, perr := strconv.Atoi64(line)
+			if perr != nil {
+				return nil, fmt.Errorf("%v: line %d: %v", fn, len(blocks)+1, perr)
+			}
+			blocks = append(blocks, offset)
+		}
+		if err == os.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return blocks, nil
+}
+
+//
+// Parse a title cache record, as read up to the next TITLE_DELIM. Version 5
+// records are still understood, so that a cache can be served while the
+// next one is built, and leave Offset, Id and Ns at 0.
+//
+func ParseTitleRecord(rec []byte) (TitleData, bool) {
+	rec = bytes.TrimRight(rec, string(TITLE_DELIM))
+	var key []byte
+	if k := bytes.IndexByte(rec, KEY_DELIM); k >= 0 {
+		rec, key = rec[:k], rec[k+1:]
+	}
+	sep := bytes.IndexByte(rec, RECORD_DELIM)
+	if sep < 0 {
+		return TitleData{}, false
+	}
+	td := TitleData{Title: string(rec[:sep]), Key: string(key)}
+	fields := strings.Split(string(rec[sep+1:]), string(FIELD_DELIM))
+
+	var err os.Error
+	switch len(fields) {
+	case 2:
+		td.Redirect = fields[1]
+	case 6:
+		td.Links, err = strconv.Atoi(fields[5])
+		fallthrough
+	case 5:
+		td.Redirect = fields[4]
+		fallthrough
+	case 4:
+		td.Offset, err = strconv.Atoi64(fields[1])
+		if err == nil {
+			td.Id, err = strconv.Atoi(fields[2])
+		}
+		if err == nil {
+			td.Ns, err = strconv.Atoi(fields[3])
+		}
+	case 1:
+	default:
+		return TitleData{}, false
+	}
+	if err == nil {
+		td.Start, err = strconv.Atoi(fields[0])
+	}
+	if err != nil {
+		return TitleData{}, false
+	}
+	return td, true
+}
+
+//
+// A namespace from the <siteinfo> at the start of a dump: Its number, its
+// name, and whether titles in it start with a capital letter
+// ("first-letter") or are left as they are ("case-sensitive").
+//
+type Namespace struct {
+	Key  int
+	Name string
+	Case string
+}
+
+////// Title file format: Version 7
+// <TITLE_DELIM>title<RECORD_DELIM>startsegment<FIELD_DELIM>offset
+//   <FIELD_DELIM>pageid<FIELD_DELIM>ns[<FIELD_DELIM>redirect
+//   [<FIELD_DELIM>links]]<KEY_DELIM>key
+// (all on one line, startsegment and offset being where the <page> starts,
+// ns being the namespace number, links how many links to the page there
+// are if they were counted, and key the title folded for search, as
+// textnorm.Key gives it.)
+//
+// With title_format frontcoded, the titles are in blocks of FrontBlockSize,
+// and only the first title in each block is there in full:
+// <TITLE_DELIM>shared<PREFIX_DELIM>rest<RECORD_DELIM>startsegment...
+// (shared being how many bytes of the title before it this one starts with,
+// and rest being what follows them.) The title index file says where each
+// block starts.
+
+//
+// Everything needed to serve one generation.
+//
+type Dataset struct {
+	Dat    map[string]string
+	Dbname string
+	Count  int
+	Blob   []byte
+	Size   int64
+	Chunks bzreader.ChunkSource
+	// Where each search routine looks in Blob. With a front coded title
+	// cache, these are block numbers rather than offsets.
+	Ranges []searchRange
+	// plain or frontcoded, and for the latter, where each block starts.
+	Format string
+	Blocks []int64
+	// The dump's namespaces, if the cache is recent enough to know them.
+	Namespaces []Namespace
+	// Whether the records have their search keys.
+	keyed bool
+	// The full-text index, if one was built, and the trigrams of the
+	// titles, likewise. Trigram page numbers are title cache order.
+	Text     *textindex.Index
+	Trigrams *textindex.Index
+	routines int
+}
+
+//
+// Open the dataset described by the dat file datfile, with the default
+// options.
+//
+func Open(datfile string) (*Dataset, os.Error) {
+	return OpenWith(datfile, DefaultOptions)
+}
+
+func OpenWith(datfile string, opts Options) (*Dataset, os.Error) {
+	d, err := confparse.ParseFile(datfile)
+	if err != nil {
+		return nil, err
+	}
+
+	ds := &Dataset{Dat: d, Dbname: d["dbname"], routines: opts.SearchRoutines}
+	if ds.routines < 1 {
+		ds.routines = 1
+	}
+	ds.Count, err = strconv.Atoi(d["rcount"])
+	if err != nil {
+		return nil, fmt.Errorf("%v: Invalid rcount: %v", datfile, err)
+	}
+	version, _ := strconv.Atoi(d["version"])
+	ds.keyed = version >= keyedVersion
+
+	ds.Namespaces = datasetNamespaces(d)
+
+	ds.Chunks, err = OpenChunks(d, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	title_file := FilePath(d, opts.TitleFile)
+	success, size, blob := loadfile.ReadFile(title_file, opts.Mmap, opts.Verbose)
+	if !success {
+		ds.Close()
+		return nil, fmt.Errorf("Unable to read %v", title_file)
+	}
+	ds.Size = size
+	ds.Blob = blob
+
+	ds.Format = Format(d)
+	if ds.Format == "frontcoded" {
+		index_file := FilePath(d, opts.TitleIndexFile)
+		ds.Blocks, err = ReadTitleIndex(index_file)
+		if err != nil {
+			ds.Close()
+			return nil, fmt.Errorf("Unable to read %v: %v", index_file, err)
+		}
+	}
+
+	if d["textindex"] == "yes" {
+		text_file := FilePath(d, opts.TextIndexFile)
+		ds.Text, err = textindex.Open(text_file, opts.Mmap)
+		if err != nil {
+			ds.Close()
+			return nil, err
+		}
+	}
+
+	if d["trigrams"] == "yes" {
+		trigram_file := FilePath(d, opts.TrigramFile)
+		ds.Trigrams, err = textindex.Open(trigram_file, opts.Mmap)
+		if err != nil {
+			ds.Close()
+			return nil, err
+		}
+	}
+
+	ds.prepSearchRanges()
+	return ds, nil
+}
+
+//
+// The ns# and nscase# keys of a dataset's dat file.
+//
+func datasetNamespaces(d map[string]string) []Namespace {
+	namespaces := []Namespace{}
+	for key, name := range d {
+		if !strings.HasPrefix(key, "ns") || strings.HasPrefix(key, "nscase") {
+			continue
+		}
+		num, err := strconv.Atoi(key[2:])
+		if err != nil {
+			continue
+		}
+		namespaces = append(namespaces, Namespace{
+			Key:  num,
+			Name: name,
+			Case: d[fmt.Sprintf("nscase%d", num)],
+		})
+	}
+	return namespaces
+}
+
+func (ds *Dataset) Close() {
+	if ds.Blob != nil {
+		loadfile.Release(ds.Blob)
+		ds.Blob = nil
+	}
+	if ds.Text != nil {
+		ds.Text.Close()
+		ds.Text = nil
+	}
+	if ds.Trigrams != nil {
+		ds.Trigrams.Close()
+		ds.Trigrams = nil
+	}
+	CloseChunks(ds.Chunks)
+	ds.Chunks = nil
+}
+
+// Compare a needle to an entry in the haystack, but do not create
+// a new string just for it.
+func caseCompare(needle, haystack []byte, hptr int64) int {
+	var i int64
+	l := int64(len(needle))
+	for i = 0; i < l; i++ {
+		if needle[i] != haystack[hptr+i] {
+			if needle[i] > haystack[hptr+i] {
+				return 1
+			} else {
+				return -1
+			}
+		}
+	}
+	if haystack[hptr+i] == RECORD_DELIM {
+		return 0
+	}
+	return -1
+}
+
+// Binary search within a blob of unequal length strings.
+func (ds *Dataset) FindTitle(name string) (TitleData, bool) {
+	if ds.Format == "frontcoded" {
+		tc := ds.Seek([]byte(name))
+		if tc.Valid() && string(tc.Title()) == name {
+			return tc.Record()
+		}
+		return TitleData{}, false
+	}
+
+	title_blob := ds.Blob
+	title_size := ds.Size
+
+	// We limit to 100, just in case.
+	searchesLeft := 100
+	needle := []byte(name)
+
+	min := int64(0)
+	max := int64(title_size)
+
+	minlen := int64(len(needle))
+
+search:
+	for {
+		// Find the halfway point.
+		if searchesLeft <= 0 {
+			break search
+		}
+		searchesLeft -= 1
+		cur := int64(((max - min) / 2) + min)
+		origcur := cur
+		if (title_size - cur) < minlen {
+			break search
+		}
+
+		// Go backwards to look for the TITLE_DELIM that signifies start of
+		// record.
+	record:
+		for {
+			if cur <= min {
+				if cur <= min {
+					// We may be very close, but searching the wrong way. Search forward,
+					// now.
+					cur = origcur
+					for {
+						if cur >= max {
+							break search
+						}
+						if title_blob[cur] == TITLE_DELIM {
+							break record
+						}
+						cur += 1
+					}
+				}
+			}
+			if title_blob[cur] == TITLE_DELIM {
+				break record
+			}
+			cur -= 1
+		}
+
+		if (max - cur) < minlen {
+			break search
+		}
+
+		recordStart := cur + 1
+		recordEnd := recordStart + 1
+		for {
+			if title_blob[recordEnd] == RECORD_DELIM {
+				break
+			}
+			recordEnd += 1
+		}
+
+		// Now we look for the <RECORD_DELIM>###(<TITLE_DELIM>|end) for the index,
+		// and the redirect, if any.
+		numStart := recordEnd + 1
+		numEnd := numStart + 1
+		for {
+			if numEnd >= title_size {
+				numEnd = title_size
+				break
+			}
+			if title_blob[numEnd] == TITLE_DELIM {
+				break
+			}
+			numEnd += 1
+		}
+
+		// Now compare
+		ret := caseCompare(needle, title_blob, recordStart)
+
+		// Did we find it? Did we?
+		if ret == 0 {
+			// We have the title.
+			return ParseTitleRecord(title_blob[recordStart:numEnd])
+		}
+
+		// Nope, let's divide and conquer.
+		if ret > 0 {
+			min = cur
+		} else if ret < 0 {
+			max = cur
+		}
+	}
+	return TitleData{}, false
+}
+
+//
+// The title of the record whose TITLE_DELIM is at pos in blob.
+//
+func titleAt(blob []byte, pos int64) []byte {
+	end := pos + 1
+	for end < int64(len(blob)) && blob[end] != RECORD_DELIM {
+		end++
+	}
+	return blob[pos+1 : end]
+}
+
+//
+// Where the record after the one at pos starts, or the end of blob if it's
+// the last.
+//
+func nextRecord(blob []byte, pos int64) int64 {
+	for pos++; pos < int64(len(blob)) && blob[pos] != TITLE_DELIM; pos++ {
+	}
+	return pos
+}
+
+//
+// Where the first record in a plain title cache with a title not less than
+// prefix starts, or the end of blob if there is none. Like FindTitle,
+// this halves the range of bytes and looks for the nearest record, but
+// never has to find an exact match.
+//
+func lowerBound(blob []byte, prefix []byte) int64 {
+	size := int64(len(blob))
+	if size == 0 || bytes.Compare(titleAt(blob, 0), prefix) >= 0 {
+		return 0
+	}
+	// The record at lo is less than prefix, the one at hi isn't.
+	lo, hi := int64(0), size
+	for {
+		mid := lo + (hi-lo)/2
+		cur := mid
+		for cur > lo && blob[cur] != TITLE_DELIM {
+			cur--
+		}
+		if cur <= lo {
+			cur = nextRecord(blob, mid)
+		}
+		if cur >= hi {
+			return hi
+		}
+		if bytes.Compare(titleAt(blob, cur), prefix) < 0 {
+			lo = cur
+		} else {
+			hi = cur
+		}
+	}
+	return hi
+}
+
+//
+// The first title of block b of a front coded title cache, which is always
+// there in full.
+//
+func (ds *Dataset) blockTitle(b int) []byte {
+	rec := ds.Blob[ds.Blocks[b]:]
+	start := bytes.IndexByte(rec, PREFIX_DELIM) + 1
+	end := bytes.IndexByte(rec, RECORD_DELIM)
+	if start <= 0 || end < start {
+		return nil
+	}
+	return rec[start:end]
+}
+
+//
+// Decode blocks first up to last of a front coded title cache into plain
+// records, so that they can be read like a plain title cache.
+//
+func (ds *Dataset) decodeBlocks(first, last int) []byte {
+	start := ds.Blocks[first]
+	end := ds.Size
+	if last < len(ds.Blocks) {
+		end = ds.Blocks[last]
+	}
+	enc := ds.Blob[start:end]
+
+	out := bytes.NewBuffer(make([]byte, 0, 2*len(enc)))
+	var title []byte
+	for len(enc) > 0 {
+		recEnd := bytes.IndexByte(enc[1:], TITLE_DELIM) + 1
+		if recEnd <= 0 {
+			recEnd = len(enc)
+		}
+		rec := enc[:recEnd]
+		enc = enc[recEnd:]
+
+		pd := bytes.IndexByte(rec, PREFIX_DELIM)
+		rd := bytes.IndexByte(rec, RECORD_DELIM)
+		if pd < 0 || rd < pd {
+			continue
+		}
+		shared, err := strconv.Atoi(string(rec[1:pd]))
+		if err != nil || shared > len(title) {
+			shared = 0
+		}
+		title = append(title[:shared], rec[pd+1:rd]...)
+
+		out.WriteByte(TITLE_DELIM)
+		out.Write(title)
+		out.Write(rec[rd:])
+	}
+	return out.Bytes()
+}
+
+//
+// Walks through the title cache in order, a record at a time, whatever
+// format it is in.
+//
+type Cursor struct {
+	ds *Dataset
+	// The plain records being walked through: All of Blob, or the block
+	// that's been decoded.
+	buf   []byte
+	block int
+	// Where the current record starts in buf.
+	pos int64
+}
+
+//
+// A cursor at the first title not less than prefix.
+//
+func (ds *Dataset) Seek(prefix []byte) *Cursor {
+	if ds.Format != "frontcoded" {
+		return &Cursor{ds: ds, buf: ds.Blob, pos: lowerBound(ds.Blob, prefix)}
+	}
+	if len(ds.Blocks) == 0 {
+		return &Cursor{ds: ds}
+	}
+
+	// The last block starting at or before prefix.
+	b := sort.Search(len(ds.Blocks), func(i int) bool {
+		return bytes.Compare(ds.blockTitle(i), prefix) > 0
+	}) - 1
+	if b < 0 {
+		b = 0
+	}
+	tc := &Cursor{ds: ds, block: b, buf: ds.decodeBlocks(b, b+1)}
+	for tc.Valid() && bytes.Compare(tc.Title(), prefix) < 0 {
+		tc.Next()
+	}
+	return tc
+}
+
+// A cursor at the first title.
+func (ds *Dataset) Titles() *Cursor {
+	return ds.Seek(nil)
+}
+
+func (tc *Cursor) Valid() bool {
+	return tc.pos < int64(len(tc.buf))
+}
+
+func (tc *Cursor) Title() []byte {
+	return titleAt(tc.buf, tc.pos)
+}
+
+func (tc *Cursor) Record() (TitleData, bool) {
+	return ParseTitleRecord(tc.buf[tc.pos+1 : nextRecord(tc.buf, tc.pos)])
+}
+
+func (tc *Cursor) Next() {
+	tc.pos = nextRecord(tc.buf, tc.pos)
+	if tc.Valid() || tc.ds.Format != "frontcoded" || tc.block+1 >= len(tc.ds.Blocks) {
+		return
+	}
+	tc.block++
+	tc.buf = tc.ds.decodeBlocks(tc.block, tc.block+1)
+	tc.pos = 0
+}
+
+// How many titles we'll look through for case folded matches in one range.
+const maxFoldWalk = 5000
+
+//
+// Up to limit titles that start with prefix, in order, beginning at the
+// first one that does. If folded is set, they must also start with it once
+// lowercased, and we give up after looking at walk titles that don't.
+//
+func (ds *Dataset) titlesWithPrefix(prefix, folded string, limit, walk int, ignore *regexp.Regexp) []string {
+	results := []string{}
+	for tc := ds.Seek([]byte(prefix)); tc.Valid() && len(results) < limit; tc.Next() {
+		title := string(tc.Title())
+		if !strings.HasPrefix(title, prefix) {
+			break
+		}
+		if folded != "" && !strings.HasPrefix(strings.ToLower(title), folded) {
+			if walk--; walk <= 0 {
+				break
+			}
+			continue
+		}
+		if ignore == nil || !ignore.MatchString(title) {
+			results = append(results, title)
+		}
+	}
+	return results
+}
+
+//
+// Every way of casing str.
+//
+func caseVariants(str string) []string {
+	variants := []string{""}
+	for _, r := range str {
+		lower, upper := string(unicode.ToLower(r)), string(unicode.ToUpper(r))
+		next := []string{}
+		for _, v := range variants {
+			next = append(next, v+lower)
+			if upper != lower {
+				next = append(next, v+upper)
+			}
+		}
+		variants = next
+	}
+	return variants
+}
+
+//
+// The titles to suggest for what someone has typed so far, up to
+// opts.Limit of them. Without opts.Fold, that's the titles starting with it
+// once normalized. With it, the title cache being sorted case sensitively,
+// we look up every casing of the first few letters and keep the titles
+// that match the rest without regard to case.
+//
+func (ds *Dataset) Suggest(typed string, opts SearchOptions) []string {
+	limit := opts.Limit
+	if limit < 1 {
+		limit = 10
+	}
+	prefix := ds.Normalize(typed)
+	if prefix == "" {
+		return []string{}
+	}
+	// Normalizing drops trailing spaces, but they matter in a prefix.
+	if last := typed[len(typed)-1]; last == ' ' || last == '_' {
+		prefix += " "
+	}
+	if !opts.Fold {
+		return ds.titlesWithPrefix(prefix, "", limit, 0, opts.Ignore)
+	}
+
+	plain := DecodeEntities(prefix)
+	head := 0
+	for i := 0; i < 4 && head < len(plain); i++ {
+		_, size := utf8.DecodeRuneInString(plain[head:])
+		head += size
+	}
+
+	folded := strings.ToLower(prefix)
+	results := []string{}
+	for _, variant := range caseVariants(plain[:head]) {
+		found := ds.titlesWithPrefix(EscapeTitle(variant), folded, limit, maxFoldWalk, opts.Ignore)
+		results = append(results, found...)
+	}
+	sort.Strings(results)
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// How many records Random looks at before giving up.
+const maxRandomTries = 1000
+
+// About the shortest a record gets, counting its TITLE_DELIM.
+const shortRecord = 10
+
+//
+// A title picked at random, each as likely as any other, leaving out
+// redirects and titles matching ignore. Records are picked until one will
+// do, so this only fails if next to none will.
+//
+func (ds *Dataset) Random(ignore *regexp.Regexp) (TitleData, bool) {
+	for tries := 0; tries < maxRandomTries; tries++ {
+		td, ok := ds.randomRecord()
+		if !ok || td.Redirect != "" {
+			continue
+		}
+		if ignore != nil && ignore.MatchString(td.Title) {
+			continue
+		}
+		return td, true
+	}
+	return TitleData{}, false
+}
+
+//
+// Any record at all, or false when the pick has to be thrown away to keep
+// things even.
+//
+func (ds *Dataset) randomRecord() (TitleData, bool) {
+	if ds.Format == "frontcoded" {
+		if len(ds.Blocks) == 0 {
+			return TitleData{}, false
+		}
+		// Every block but the last is full, so a spot past the end of
+		// that one is thrown away.
+		b := rand.Intn(len(ds.Blocks))
+		buf := ds.decodeBlocks(b, b+1)
+		var pos int64
+		for n := rand.Intn(FrontBlockSize); n > 0 && pos < int64(len(buf)); n-- {
+			pos = nextRecord(buf, pos)
+		}
+		if pos >= int64(len(buf)) {
+			return TitleData{}, false
+		}
+		return ParseTitleRecord(buf[pos+1 : nextRecord(buf, pos)])
+	}
+
+	if ds.Size == 0 {
+		return TitleData{}, false
+	}
+	// A random byte lands in a long record more often than in a short one,
+	// so a record is only kept shortRecord times out of its length.
+	start := rand.Int63n(ds.Size)
+	for start > 0 && ds.Blob[start] != TITLE_DELIM {
+		start--
+	}
+	end := nextRecord(ds.Blob, start)
+	if rand.Int63n(end-start) >= shortRecord {
+		return TitleData{}, false
+	}
+	return ParseTitleRecord(ds.Blob[start+1 : end])
+}
+
+var wholetextrx = regexp.MustCompile("<text[^>]*>(.*)</text>")
+var starttextrx = regexp.MustCompile("<text[^>]*>(.*)")
+var endtextrx = regexp.MustCompile("(.*)</text>")
+
+//
+// What the dump says about the revision of a page it has. These are as they
+// are in the dump: XML escaped.
+//
+type Revision struct {
+	Id          int
+	Timestamp   string
+	Contributor string
+	Comment     string
+	Minor       bool
+}
+
+var revisiontagrx = regexp.MustCompile("^[ \t]*<([a-z]+)[^>/]*>([^<]*)</[a-z]+>")
+
+//
+// Picks the revision out of the lines between a page's <title> and its
+// <text>. There are <id>s for the page before the <revision> and for the
+// user in the <contributor>, so only the one right in the <revision> is
+// the revision's.
+//
+type revisionReader struct {
+	Revision
+	inRevision, inContributor bool
+}
+
+func (rr *revisionReader) AddLine(line string) {
+	tag := strings.TrimSpace(line)
+	switch {
+	case strings.HasPrefix(tag, "<revision"):
+		rr.inRevision = true
+		return
+	case strings.HasPrefix(tag, "<contributor"):
+		// <contributor deleted="deleted" /> has nothing in it.
+		rr.inContributor = !strings.HasSuffix(tag, "/>")
+		return
+	case strings.HasPrefix(tag, "</contributor"):
+		rr.inContributor = false
+		return
+	case strings.HasPrefix(tag, "<minor"):
+		rr.Minor = rr.inRevision
+		return
+	}
+	if !rr.inRevision {
+		return
+	}
+
+	matches := revisiontagrx.FindStringSubmatch(line)
+	if matches == nil {
+		return
+	}
+	switch matches[1] {
+	case "id":
+		if !rr.inContributor && rr.Id == 0 {
+			rr.Id, _ = strconv.Atoi(matches[2])
+		}
+	case "timestamp":
+		rr.Timestamp = matches[2]
+	case "username", "ip":
+		if rr.inContributor {
+			rr.Contributor = matches[2]
+		}
+	case "comment":
+		rr.Comment = matches[2]
+	}
+}
+
+//
+// Read the wikitext of td, and what revision of it this is. Since version
+// 6 we know where in the chunk its <page> is and skip straight to it, but
+// the <title> is still checked, and if it isn't there we look again from
+// the start of the chunk.
+//
+func (ds *Dataset) ReadTitle(td TitleData) (string, Revision) {
+	if td.Offset > 0 {
+		if text, rev, ok := ds.readTitleFrom(td, td.Offset); ok {
+			return text, rev
+		}
+	}
+	text, rev, _ := ds.readTitleFrom(td, 0)
+	return text, rev
+}
+
+func (ds *Dataset) readTitleFrom(td TitleData, offset int64) (string, Revision, bool) {
+	var str string
+	var err os.Error
+	var rr revisionReader
+
+	toFind := fmt.Sprintf("<title>%s</title>", td.Title)
+
+	// Start looking for the title.
+	bzr := bzreader.NewSourceReader(ds.Chunks, td.Start)
+	defer bzr.Close()
+
+	if offset > 0 && bzr.Skip(offset) != nil {
+		return "", Revision{}, false
+	}
+
+	// Past the offset, the <title> should be the next line but one.
+	toFindb := []byte(toFind)
+	for lines := 0; ; lines++ {
+		bstr, berr := bzr.ReadBytes()
+		if berr != nil || (offset > 0 && lines > 2) {
+			return "", Revision{}, false
+		}
+		if bytes.Index(bstr, toFindb) >= 0 {
+			break
+		}
+	}
+
+	toFind = "<text"
+	for {
+		str, err = bzr.ReadString()
+		if err != nil {
+			return "", rr.Revision, true
+		}
+		if strings.Contains(str, toFind) {
+			break
+		}
+		rr.AddLine(str)
+	}
+
+	// We found <text> in string. Capture everything after it.
+	// It may contain </text>
+	matches := wholetextrx.FindStringSubmatch(str)
+	if matches != nil {
+		return matches[1], rr.Revision, true
+	}
+
+	// Otherwise, it just has <text>
+	buffer := bytes.NewBufferString("")
+
+	matches = starttextrx.FindStringSubmatch(str)
+	if matches != nil {
+		fmt.Fprint(buffer, matches[1])
+	}
+
+	toFind = "</text>"
+	for {
+		str, err = bzr.ReadString()
+		if err != nil {
+			return "", rr.Revision, true
+		}
+		if strings.Contains(str, toFind) {
+			break
+		}
+		fmt.Fprint(buffer, str)
+	}
+
+	matches = endtextrx.FindStringSubmatch(str)
+	if matches != nil {
+		fmt.Fprint(buffer, matches[1])
+	}
+
+	return string(buffer.Bytes()), rr.Revision, true
+}
+
+var entityNames = map[string]int{
+	"amp":  '&',
+	"lt":   '<',
+	"gt":   '>',
+	"quot": '"',
+	"apos": '\'',
+	"nbsp": 0xA0,
+}
+
+// The character an entity name (without & and ;) stands for.
+func entityRune(name string) (int, bool) {
+	if r, ok := entityNames[name]; ok {
+		return r, true
+	}
+	if len(name) < 2 || name[0] != '#' {
+		return 0, false
+	}
+	var num uint64
+	var err os.Error
+	if name[1] == 'x' || name[1] == 'X' {
+		num, err = strconv.Btoui64(name[2:], 16)
+	} else {
+		num, err = strconv.Btoui64(name[1:], 10)
+	}
+	if err != nil || num == 0 || num > unicode.MaxRune {
+		return 0, false
+	}
+	return int(num), true
+}
+
+//
+// Turn &amp;, &#233; and the like into the characters they stand for.
+// Anything that doesn't look like an entity is left alone.
+//
+func DecodeEntities(str string) string {
+	if !strings.Contains(str, "&") {
+		return str
+	}
+	buff := bytes.NewBuffer(nil)
+	for {
+		amp := strings.Index(str, "&")
+		if amp < 0 {
+			break
+		}
+		buff.WriteString(str[:amp])
+		str = str[amp:]
+		semi := strings.Index(str, ";")
+		r, ok := 0, false
+		if semi > 0 {
+			r, ok = entityRune(str[1:semi])
+		}
+		if ok {
+			buff.WriteRune(r)
+			str = str[semi+1:]
+		} else {
+			buff.WriteByte('&')
+			str = str[1:]
+		}
+	}
+	buff.WriteString(str)
+	return buff.String()
+}
+
+func isTitleSpace(r int) bool {
+	return r == '_' || unicode.IsSpace(r)
+}
+
+//
+// Turn a title the way it comes in a URL or a link into the way MediaWiki
+// stores it, which is how it is in the title cache:
+//
+// Entities are decoded and the result is composed into NFC. Percent
+// escapes are left alone: Those in URLs are decoded once by the HTTP server
+// already, and a title can have a % of its own. Underscores are spaces, and
+// runs of them are collapsed into one. A namespace prefix gets the
+// namespace's own spelling, and the first letter after it is capitalized
+// unless the namespace says otherwise. Finally, it's XML escaped, as in the
+// dump.
+//
+func (ds *Dataset) Normalize(str string) string {
+	str = textnorm.NFC(DecodeEntities(str))
+	str = strings.Join(strings.FieldsFunc(str, isTitleSpace), " ")
+	// A leading colon only means "not a namespace" in links.
+	str = strings.TrimLeft(str, ": ")
+
+	prefix, rest := "", str
+	caseRule := ""
+	if colon := strings.Index(str, ":"); colon > 0 {
+		name := strings.ToLower(strings.TrimSpace(str[:colon]))
+		for _, ns := range ds.Namespaces {
+			if ns.Name != "" && strings.ToLower(ns.Name) == name {
+				prefix = ns.Name + ":"
+				rest = strings.TrimSpace(str[colon+1:])
+				caseRule = ns.Case
+				break
+			}
+		}
+	}
+	if prefix == "" {
+		for _, ns := range ds.Namespaces {
+			if ns.Key == 0 {
+				caseRule = ns.Case
+			}
+		}
+	}
+
+	// Caches from before namespaces were kept: Wikipedia's rules.
+	if caseRule != "case-sensitive" && rest != "" {
+		r, size := utf8.DecodeRuneInString(rest)
+		rest = string(unicode.ToUpper(r)) + rest[size:]
+	}
+	return EscapeTitle(prefix + rest)
+}
+
+//
+// Look for a title that only differs from name in case, as someone typing
+// a URL by hand is apt to do. The titles search finds are narrowed down to
+// those that are the same once lowercased, and the first of those wins.
+// The search results are handed back as well, for offering instead if
+// nothing turns up.
+//
+func (ds *Dataset) LookupFold(name string, opts SearchOptions) (string, []string) {
+	// Search only goes by letters and digits.
+	plain := DecodeEntities(name)
+	key := strings.Map(func(r int) int {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, plain)
+	if key == "" {
+		return "", nil
+	}
+
+	results := ds.Search(key, opts)
+	lower := strings.ToLower(name)
+	for _, title := range results {
+		if strings.ToLower(title) == lower {
+			return title, results
+		}
+	}
+	return "", results
+}
+
+// How many redirects in a row we'll follow.
+const maxRedirects = 5
+
+var wikiredirectrx = regexp.MustCompile("^[ \t\n]*#[Rr][Ee][Dd][Ii][Rr][Ee][Cc][Tt][ \t]*:?[ \t]*\\[\\[([^\\]|]*)")
+
+//
+// Where the text of a page says it redirects to, for when the title cache
+// doesn't know: Multistream dumps, and caches from before redirects.
+//
+func wikiRedirect(text string) string {
+	matches := wikiredirectrx.FindStringSubmatch(text)
+	if matches == nil {
+		return ""
+	}
+	return matches[1]
+}
+
+//
+// A page as read from the dump.
+//
+type Page struct {
+	TitleData
+	Text     string
+	Revision Revision
+	// The title we were redirected from, if we were.
+	RedirectedFrom string
+}
+
+//
+// Read the page td, following its redirects unless told not to. Returns
+// the page we end up at. A redirect that loops or goes nowhere leaves us
+// on the last redirect page.
+//
+func (ds *Dataset) ReadPage(td TitleData, follow bool) *Page {
+	page := &Page{}
+	seen := map[string]bool{}
+	for hops := 0; ; hops++ {
+		seen[td.Title] = true
+		page.TitleData = td
+		page.Text, page.Revision = ds.ReadTitle(td)
+		if !follow || hops >= maxRedirects {
+			return page
+		}
+
+		target := td.Redirect
+		if target == "" {
+			// As in any link, MediaWiki takes percent escapes in
+			// #REDIRECT [[...]].
+			target = wikiRedirect(page.Text)
+			if unescaped, err := http.URLUnescape(target); err == nil {
+				target = unescaped
+			}
+		}
+		if target == "" {
+			return page
+		}
+		// Sections aren't part of the title.
+		if hash := strings.Index(target, "#"); hash >= 0 {
+			target = target[:hash]
+		}
+
+		next, ok := ds.FindTitle(ds.Normalize(target))
+		if !ok || seen[next.Title] {
+			return page
+		}
+		if page.RedirectedFrom == "" {
+			page.RedirectedFrom = td.Title
+		}
+		td = next
+	}
+	return page
+}
+
+//
+// Look up a title the way it'd be typed or linked to, normalizing it first.
+//
+func (ds *Dataset) Lookup(title string) (TitleData, bool) {
+	return ds.FindTitle(ds.Normalize(title))
+}
+
+//
+// The wikitext of the page title, following redirects, or an error if
+// there's no such page.
+//
+func (ds *Dataset) ReadWikitext(title string) (string, os.Error) {
+	td, ok := ds.Lookup(title)
+	if !ok {
+		return "", fmt.Errorf("No such page: %v", title)
+	}
+	return ds.ReadPage(td, true).Text, nil
+}
+
+// The record pos is somewhere in.
+func recordAt(haystack []byte, pos int) (TitleData, bool) {
+	var i, end int
+	for i = pos; i > 0 && haystack[i] != TITLE_DELIM; i -= 1 {
+	}
+	for end = pos; end < len(haystack) && haystack[end] != TITLE_DELIM; end++ {
+	}
+	return ParseTitleRecord(haystack[i+1 : end])
+}
+
+//
+// What the search routines hand back: The titles found, the redirects
+// found, and how many matches there were in each namespace, whatever was
+// left out.
+//
+type searchFinds struct {
+	hits      []Hit
+	redirects []TitleData
+	facets    map[int]int
+}
+
+func newSearchFinds() *searchFinds {
+	return &searchFinds{hits: []Hit{}, facets: map[int]int{}}
+}
+
+func (sf *searchFinds) add(more *searchFinds) {
+	sf.hits = append(sf.hits, more.hits...)
+	sf.redirects = append(sf.redirects, more.redirects...)
+	for ns, count := range more.facets {
+		sf.facets[ns] += count
+	}
+}
+
+// How many blocks of a front coded title cache to decode at once when
+// searching.
+const searchBlocks = 256
+
+//
+// caseInsensitiveFinds for a front coded title cache: Blocks first up to
+// last are decoded a few at a time and searched like a plain title cache.
+//
+func (ds *Dataset) blockFinds(first, last int, needle []byte, opts SearchOptions, watchdog chan *searchFinds) {
+	finds := caseInsensitiveFinds
+	if ds.keyed {
+		finds = keyFinds
+	}
+	results := newSearchFinds()
+	found := make(chan *searchFinds, 1)
+	for b := first; b < last; b += searchBlocks {
+		end := b + searchBlocks
+		if end > last {
+			end = last
+		}
+		finds(ds.decodeBlocks(b, end), needle, opts, found)
+		results.add(<-found)
+	}
+	watchdog <- results
+}
+
+//
+// caseInsensitiveFinds for a title cache whose records have their keys,
+// needle being a key too: Folding and leaving out everything but letters
+// and digits has already been done for every title, so all that's left is
+// to look for needle as it is, in the keys.
+//
+func keyFinds(haystack, needle []byte, opts SearchOptions, watchdog chan *searchFinds) {
+	results := newSearchFinds()
+	defer func() {
+		watchdog <- results
+	}()
+	if len(needle) == 0 {
+		return
+	}
+
+	for i := 0; i < len(haystack); {
+		found := bytes.Index(haystack[i:], needle)
+		if found < 0 {
+			break
+		}
+		pos := i + found
+		// Keys have nothing but letters and digits in them, so what's just
+		// before one says whether this is in a key or elsewhere.
+		start := pos
+		for start > 0 && !isDelim(haystack[start-1]) {
+			start--
+		}
+		i = pos + 1
+		if start == 0 || haystack[start-1] != KEY_DELIM {
+			continue
+		}
+
+		td, ok := recordAt(haystack, pos)
+		if ok && td.Redirect != "" {
+			results.redirects = append(results.redirects, td)
+		} else if ok {
+			results.facets[td.Ns]++
+			if opts.wants(td) {
+				results.hits = append(results.hits, Hit{TitleData: td})
+			}
+		}
+		// Once per record.
+		for i < len(haystack) && haystack[i] != TITLE_DELIM {
+			i++
+		}
+	}
+}
+
+func isDelim(c byte) bool {
+	switch c {
+	case TITLE_DELIM, RECORD_DELIM, FIELD_DELIM, PREFIX_DELIM, KEY_DELIM:
+		return true
+	}
+	return false
+}
+
+// How we do searches:
+//
+// caseInsensitiveFinds() searches through haystack, which ideally is already
+// properly bounded.
+//
+// First, it turns needle into both an upper case and lower case copy,
+// so it can use both for quick reference. It discards all non-alphanumeric
+// runes from needle so that "cslewis" will match "C. S. Lewis"
+//
+// Searching in the haystack also ignores non-alphanumeric runes.
+func caseInsensitiveFinds(haystack, needle []byte, opts SearchOptions, watchdog chan *searchFinds) {
+	results := newSearchFinds()
+
+	defer func() {
+		watchdog <- results
+	}()
+
+	n := len(needle)
+	if n == 0 {
+		return
+	}
+
+	var urunes []int
+	if true {
+		tmp := bytes.ToUpper(needle)
+		urunes = []int{}[:]
+
+		i := 0
+		for j := 0; j < len(tmp); {
+			rune, cnt := utf8.DecodeRune(tmp[j:])
+			j += cnt
+			// Strip out all spaces.
+			if !unicode.IsSpace(rune) {
+				urunes = append(urunes, rune)
+				i += 1
+			}
+		}
+	}
+
+	var lrunes []int
+	if true {
+		tmp := bytes.ToLower(needle)
+		lrunes = []int{}[:]
+
+		i := 0
+		for j := 0; j < len(tmp); {
+			rune, cnt := utf8.DecodeRune(tmp[j:])
+			j += cnt
+			// Strip out all spaces.
+			if !unicode.IsSpace(rune) {
+				lrunes = append(lrunes, rune)
+				i += 1
+			}
+		}
+	}
+
+	if len(lrunes) < 1 {
+		return
+	}
+
+	lc := lrunes[0]
+	uc := urunes[0]
+	n = len(lrunes)
+
+	maxlen := len(haystack)
+
+nextrecord:
+	for i := 0; (i + n) < maxlen; {
+		// Past the title, there's only the start chunk and redirect.
+		if haystack[i] == RECORD_DELIM {
+			for ; i < maxlen && haystack[i] != TITLE_DELIM; i++ {
+			}
+			continue nextrecord
+		}
+
+		r, cnt := utf8.DecodeRune(haystack[i:])
+		i += cnt
+
+		// Check the first rune against either the lower or upper case needle
+		// rune.
+		if r == lc || r == uc {
+			x := i
+			var s int
+
+			// If r is 0-9, then it could be we're looking at a record number in
+			// the haystack. Make sure this doesn't happen.
+
+			if r >= '0' && r <= '9' {
+				// Skip over the next digits
+				ptr := i
+				for ; ptr < maxlen && haystack[ptr] >= '0' && haystack[ptr] <= '9'; ptr++ {
+				}
+
+				// If it ends at a TITLE_DELIM, then this is not a match.
+				if ptr >= maxlen || haystack[ptr] == TITLE_DELIM {
+					i = ptr
+					continue nextrecord
+				}
+			}
+
+			// Check the rest.
+			for s = 1; s < n; s++ {
+				// Skip over all non-alphanumerics.
+				var r, cnt int
+				for {
+					if haystack[x] == RECORD_DELIM || haystack[x] == TITLE_DELIM {
+						break
+					}
+					r, cnt = utf8.DecodeRune(haystack[x:])
+					x += cnt
+					if unicode.IsLetter(r) || unicode.IsDigit(r) {
+						break
+					}
+				}
+				if !(r == urunes[s] || r == lrunes[s]) {
+					break
+				}
+			}
+			if s >= n {
+				td, ok := recordAt(haystack, i)
+				// Redirects only count for where they go.
+				if ok && td.Redirect != "" {
+					results.redirects = append(results.redirects, td)
+				} else if ok {
+					results.facets[td.Ns]++
+					if opts.wants(td) {
+						results.hits = append(results.hits, Hit{TitleData: td})
+					}
+				}
+				for {
+					if i > maxlen || haystack[i] == TITLE_DELIM {
+						break
+					}
+					i += 1
+				}
+			}
+		}
+	}
+}
+
+//
+// How to search, and what to leave out.
+//
+type SearchOptions struct {
+	// Titles matching Ignore are left out of the results, if it's set.
+	Ignore *regexp.Regexp
+	// Only titles in these namespaces, if any are given. Ignore doesn't
+	// apply then: Asking for a namespace means wanting to see it.
+	Namespaces []int
+	// For Suggest: How many titles at most, and whether case matters.
+	Limit int
+	Fold  bool
+}
+
+func (opts SearchOptions) wants(td TitleData) bool {
+	if len(opts.Namespaces) > 0 {
+		for _, ns := range opts.Namespaces {
+			if td.Ns == ns {
+				return true
+			}
+		}
+		return false
+	}
+	return opts.Ignore == nil || !opts.Ignore.MatchString(td.Title)
+}
+
+//
+// Search all the titles for phrase, ignoring case, spaces and punctuation,
+// using all the search routines. The results are sorted best first, as
+// SearchHits explains.
+//
+func (ds *Dataset) Search(phrase string, opts SearchOptions) []string {
+	titles, _ := ds.SearchFacets(phrase, opts)
+	return titles
+}
+
+//
+// Search, also counting the titles found in each namespace, including
+// those opts left out, so that people can tell there's more to be had.
+// Caches from before namespaces were kept have everything in 0.
+//
+func (ds *Dataset) SearchFacets(phrase string, opts SearchOptions) ([]string, map[int]int) {
+	hits, facets := ds.SearchHits(phrase, opts)
+	titles := make([]string, len(hits))
+	for i, hit := range hits {
+		titles[i] = hit.Title
+	}
+	return titles, facets
+}
+
+//
+// A title search turned up, and how well it matches.
+//
+type Hit struct {
+	TitleData
+	Score int
+	// The redirect to it that matched better than it did, if one did.
+	Alias string
+}
+
+// What a hit's score is made of. A title matching at the start of a word
+// gets more if the phrase ends at the end of one.
+const (
+	scoreExact     = 1000
+	scorePrefix    = 400
+	scoreWord      = 200
+	scoreWholeWord = 100
+	// How much of that a title gets for a redirect to it matching.
+	aliasPercent = 80
+	// For every doubling of the links to it.
+	scoreLinks = 20
+)
+
+//
+// SearchFacets, scored: Redirects aren't results themselves, but count for
+// where they go. The titles are scored by how phrase matches them (or the
+// best redirect to them), plus how many pages link to them if the links
+// were counted at ingest.
+//
+func (ds *Dataset) SearchHits(phrase string, opts SearchOptions) ([]Hit, map[int]int) {
+	words := textnorm.Words(EscapeTitle(phrase))
+	key := strings.Join(words, "")
+
+	var allresults *searchFinds
+	if ds.Trigrams != nil && utf8.RuneCountInString(key) >= 3 {
+		allresults = ds.trigramFinds(key, opts)
+	}
+	// No trigrams, or too many titles have them: Go through every title.
+	if allresults == nil && ds.keyed {
+		allresults = ds.scanFinds(key, opts)
+	} else if allresults == nil {
+		allresults = ds.scanFinds(phrase, opts)
+	}
+
+	hits := allresults.hits
+	found := map[string]int{}
+	for i := range hits {
+		hits[i].Score = matchScore(words, hits[i].Title) + linkScore(hits[i].Links)
+		found[hits[i].Title] = i
+	}
+
+	for _, rd := range allresults.redirects {
+		score := matchScore(words, rd.Title) * aliasPercent / 100
+		if i, ok := found[rd.Redirect]; ok {
+			if i >= 0 && score+linkScore(hits[i].Links) > hits[i].Score {
+				hits[i].Score = score + linkScore(hits[i].Links)
+				hits[i].Alias = rd.Title
+			}
+			continue
+		}
+		td, ok := ds.FindTitle(rd.Redirect)
+		if !ok || td.Redirect != "" {
+			continue
+		}
+		// Found by way of the redirect, so it counts like any other find.
+		allresults.facets[td.Ns]++
+		if !opts.wants(td) {
+			found[td.Title] = -1
+			continue
+		}
+		found[td.Title] = len(hits)
+		hits = append(hits, Hit{TitleData: td, Score: score + linkScore(td.Links), Alias: rd.Title})
+	}
+
+	hitlist(hits).Sort()
+	return hits, allresults.facets
+}
+
+//
+// How well a title matches the words of a phrase: Exactly, at its start,
+// at the start of one of its words, or (0) somewhere in the middle. Like
+// search, this goes by letters and digits, ignoring case.
+//
+func matchScore(words []string, title string) int {
+	want := strings.Join(words, "")
+	have := textnorm.Words(title)
+	if want == "" {
+		return 0
+	}
+	for i := range have {
+		rest := strings.Join(have[i:], "")
+		if !strings.HasPrefix(rest, want) {
+			continue
+		}
+		if i == 0 && rest == want {
+			return scoreExact
+		}
+		score := scoreWord
+		if i == 0 {
+			score = scorePrefix
+		}
+		if endsAtWord(have[i:], len(want)) {
+			score += scoreWholeWord
+		}
+		return score
+	}
+	return 0
+}
+
+// Whether the first n bytes of words, joined together, end where a word
+// does.
+func endsAtWord(words []string, n int) bool {
+	for _, word := range words {
+		n -= len(word)
+		if n <= 0 {
+			break
+		}
+	}
+	return n == 0
+}
+
+// scoreLinks for every doubling of links.
+func linkScore(links int) int {
+	score := 0
+	for ; links > 0; links >>= 1 {
+		score += scoreLinks
+	}
+	return score
+}
+
+// How many pages that have every word of a query with phrases in it are
+// read to check that the phrases are really there.
+const maxPhraseChecks = 200
+
+//
+// The titles of the pages that have every word of query in them, and its
+// "quoted phrases" as they are, in the order they're in the dump. Nothing
+// without a text index. Only so many pages are read to look for the
+// phrases, so if there are more than that with every word, complete is
+// false and the results are only those found so far.
+//
+func (ds *Dataset) SearchText(query string, opts SearchOptions) (results []string, complete bool) {
+	results = []string{}
+	if ds.Text == nil {
+		return results, true
+	}
+	q := textindex.ParseQuery(query)
+	checked := 0
+	for _, doc := range ds.Text.Match(q.Words) {
+		td, ok := ds.FindTitle(ds.Text.Title(doc))
+		if !ok || td.Redirect != "" || !opts.wants(td) {
+			continue
+		}
+		if len(q.Phrases) > 0 {
+			if checked >= maxPhraseChecks {
+				return results, false
+			}
+			checked++
+			text, _ := ds.ReadTitle(td)
+			if !textindex.HasPhrases(textnorm.Words(text), q.Phrases) {
+				continue
+			}
+		}
+		results = append(results, td.Title)
+	}
+	return results, true
+}
+
+//
+// Search every title for phrase, using all the search routines. If the
+// records have their keys, phrase is a key, and that's what's searched.
+//
+func (ds *Dataset) scanFinds(phrase string, opts SearchOptions) *searchFinds {
+	// A watchdog for the goroutines.
+	watchdog := make(chan *searchFinds)
+
+	// Start all goroutine for searching.
+	for i := 0; i < ds.routines; i++ {
+		go func(s, e int64, w chan *searchFinds) {
+			if ds.Format == "frontcoded" {
+				ds.blockFinds(int(s), int(e), []byte(phrase), opts, w)
+			} else if ds.keyed {
+				keyFinds(ds.Blob[s:e], []byte(phrase), opts, w)
+			} else {
+				caseInsensitiveFinds(ds.Blob[s:e], []byte(phrase), opts, w)
+			}
+		}(ds.Ranges[i].Start, ds.Ranges[i].End, watchdog)
+	}
+
+	// First results
+	allresults := <-watchdog
+
+	for i := 1; i < ds.routines; i++ {
+		allresults.add(<-watchdog)
+	}
+	return allresults
+}
+
+// Past this many titles with every trigram of a phrase, looking each one up
+// costs more than going through all of them.
+const trigramCandidates = 20000
+
+//
+// Search only the titles that have every trigram of key in them, as the
+// trigram index says, for key itself. key is the letters and digits of the
+// phrase, lowercased, as textnorm.Key gives them. Going through a few
+// posting lists and the titles they point to is much less work than going
+// through every title, and much less reading from the disk with mmap.
+//
+// That's not so when the trigrams are all common ones, as in "the": nil
+// then, past trigramCandidates titles, for the caller to go through every
+// title after all.
+//
+func (ds *Dataset) trigramFinds(key string, opts SearchOptions) *searchFinds {
+	docs, ok := ds.Trigrams.MatchAtMost(textindex.Trigrams(key), trigramCandidates)
+	if !ok {
+		return nil
+	}
+	results := newSearchFinds()
+	for _, doc := range docs {
+		title := ds.Trigrams.Title(doc)
+		if !strings.Contains(textnorm.Key(title), key) {
+			continue
+		}
+		td, ok := ds.FindTitle(title)
+		if !ok {
+			continue
+		}
+		if td.Redirect != "" {
+			results.redirects = append(results.redirects, td)
+			continue
+		}
+		results.facets[td.Ns]++
+		if opts.wants(td) {
+			results.hits = append(results.hits, Hit{TitleData: td})
+		}
+	}
+	return results
+}
+
+// How many edits a fuzzy search allows: One for short phrases, two past
+// fuzzyLong characters.
+const fuzzyLong = 7
+
+// Fuzzy searches on phrases shorter than this would find most anything.
+const fuzzyShortest = 4
+
+// What a fuzzy find scores for every edit fewer than the most allowed.
+const scoreFuzzy = 100
+
+//
+// Titles with something in them that's at most an edit or two away from
+// phrase, going by letters and digits only: "Einstien" finds "Albert
+// Einstein". They're scored by how few edits it took, plus how many pages
+// link to them, and redirects count for where they go, as with
+// SearchHits. Nothing without a trigram index.
+//
+// Every edit spoils at most three trigrams, so titles sharing too few
+// trigrams with phrase needn't be looked at. Fewer than half is taken to be
+// too few even when that would allow a little more, to keep the titles to
+// look at down.
+//
+func (ds *Dataset) SearchFuzzy(phrase string, opts SearchOptions) []Hit {
+	hits := []Hit{}
+	want := []int(textnorm.Key(EscapeTitle(phrase)))
+	if ds.Trigrams == nil || len(want) < fuzzyShortest {
+		return hits
+	}
+	edits := 1
+	if len(want) > fuzzyLong {
+		edits = 2
+	}
+	trigrams := textindex.Trigrams(string(want))
+	needed := len(trigrams) - 3*edits
+	if needed < (len(trigrams)+1)/2 {
+		needed = (len(trigrams) + 1) / 2
+	}
+
+	shared := map[int]int{}
+	for _, trigram := range trigrams {
+		for _, doc := range ds.Trigrams.Postings(trigram) {
+			shared[doc]++
+		}
+	}
+
+	found := map[string]bool{}
+	for doc, count := range shared {
+		if count < needed {
+			continue
+		}
+		title := ds.Trigrams.Title(doc)
+		distance := substringDistance(want, []int(textnorm.Key(title)))
+		if distance > edits {
+			continue
+		}
+		td, ok := ds.FindTitle(title)
+		if ok && td.Redirect != "" {
+			td, ok = ds.FindTitle(td.Redirect)
+		}
+		if !ok || td.Redirect != "" || found[td.Title] || !opts.wants(td) {
+			continue
+		}
+		found[td.Title] = true
+		hits = append(hits, Hit{TitleData: td, Score: (edits-distance+1)*scoreFuzzy + linkScore(td.Links)})
+	}
+	hitlist(hits).Sort()
+	return hits
+}
+
+//
+// The fewest edits (a character put in, left out or changed) that turn
+// pattern into some part of text.
+//
+func substringDistance(pattern, text []int) int {
+	// How many edits pattern[:i] takes to end where we are in text.
+	col := make([]int, len(pattern)+1)
+	for i := range col {
+		col[i] = i
+	}
+	best := col[len(pattern)]
+	for _, c := range text {
+		diag := col[0]
+		for i := 1; i <= len(pattern); i++ {
+			cost := diag
+			if pattern[i-1] != c {
+				cost++
+			}
+			if col[i]+1 < cost {
+				cost = col[i] + 1
+			}
+			if col[i-1]+1 < cost {
+				cost = col[i-1] + 1
+			}
+			diag = col[i]
+			col[i] = cost
+		}
+		if col[len(pattern)] < best {
+			best = col[len(pattern)]
+		}
+	}
+	return best
+}
+
+// Prepare what's needed for fast searching of a dataset.
+//
+// type searchRange struct { Start, End int }
+// ds.Ranges []searchRange
+//
+// What this does is pre-split the db ('haystack') into approximately equal
+// portions, bounded by TITLE_DELIM characters and the beginning and end of
+// the titlecache file.
+//
+// A setup with a single search routine would have Start = 1 and End = title_size
+func (ds *Dataset) prepSearchRanges() {
+	if ds.Format == "frontcoded" {
+		ds.prepBlockRanges()
+		return
+	}
+
+	title_blob := ds.Blob
+	title_size := ds.Size
+	searchRanges := make([]searchRange, ds.routines)
+
+	if ds.routines > 1 {
+		mult := title_size / int64(ds.routines)
+		ptr := int64(0)
+		for i := 0; i < ds.routines; i++ {
+			// Start at the end of the last one.
+			searchRanges[i].Start = ptr
+			ptr = mult * int64((i + 1))
+			if ptr >= title_size {
+				ptr = title_size
+			} else {
+				for {
+					if title_blob[ptr] == TITLE_DELIM {
+						break
+					}
+					if ptr < searchRanges[i].Start {
+						fmt.Printf("Something is wrong with your titleCache.dat!\n")
+						panic("Invalid titleCache.dat")
+					}
+					ptr--
+				}
+			}
+			searchRanges[i].End = ptr
+		}
+	} else {
+		searchRanges[0].Start = 0
+		searchRanges[0].End = title_size
+	}
+	ds.Ranges = searchRanges
+}
+
+// Split the blocks of a front coded title cache between the search
+// routines.
+//
+func (ds *Dataset) prepBlockRanges() {
+	searchRanges := make([]searchRange, ds.routines)
+	blocks := int64(len(ds.Blocks))
+	for i := 0; i < ds.routines; i++ {
+		searchRanges[i].Start = blocks * int64(i) / int64(ds.routines)
+		searchRanges[i].End = blocks * int64(i+1) / int64(ds.routines)
+	}
+	ds.Ranges = searchRanges
+}
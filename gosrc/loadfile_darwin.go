@@ -5,10 +5,20 @@ package loadfile
 import (
 	"fmt"
 	"os"
+	"sync"
 	"syscall"
 )
 
-func ReadFile(title_file string, dommap bool) (bool, int64, []byte) {
+// The blobs ReadFile has mmaped, by their first byte. Datasets are loaded
+// and let go of from more than one goroutine, so hold mappedLock for it.
+var mapped = map[*byte]bool{}
+var mappedLock sync.Mutex
+
+//
+// Read in title_file, or mmap it if dommap. Unless verbose, only what goes
+// wrong is mentioned.
+//
+func ReadFile(title_file string, dommap, verbose bool) (bool, int64, []byte) {
 	fin, err := os.Open(title_file)
 	if err != nil {
 		fmt.Println(err)
@@ -38,7 +48,12 @@ func ReadFile(title_file string, dommap bool) (bool, int64, []byte) {
 			syscall.MAP_PRIVATE)
 		if errno == 0 {
 			file_blob = addr
-			fmt.Printf("Successfully mmaped!\n")
+			mappedLock.Lock()
+			mapped[&file_blob[0]] = true
+			mappedLock.Unlock()
+			if verbose {
+				fmt.Printf("Successfully mmaped!\n")
+			}
 		} else {
 			fmt.Printf("Unable to mmap! error: '%v'\n", os.Errno(errno))
 			dommap = false
@@ -46,7 +61,9 @@ func ReadFile(title_file string, dommap bool) (bool, int64, []byte) {
 	}
 	if !dommap {
 		// Default: Load into memory.
-		fmt.Printf("Loading titlecache.dat into Memory . . .\n")
+		if verbose {
+			fmt.Printf("Loading titlecache.dat into Memory . . .\n")
+		}
 		file_blob = make([]byte, file_size, file_size)
 
 		nread, err := fin.Read(file_blob)
@@ -63,3 +80,17 @@ func ReadFile(title_file string, dommap bool) (bool, int64, []byte) {
 	}
 	return true, file_size, file_blob
 }
+
+// Let go of a blob from ReadFile that isn't going to be used any more.
+func Release(file_blob []byte) {
+	if len(file_blob) == 0 {
+		return
+	}
+	mappedLock.Lock()
+	wasMapped := mapped[&file_blob[0]]
+	mapped[&file_blob[0]] = false, false
+	mappedLock.Unlock()
+	if wasMapped {
+		syscall.Munmap(file_blob)
+	}
+}
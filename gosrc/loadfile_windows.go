@@ -7,7 +7,11 @@ import (
 	"os"
 )
 
-func ReadFile(title_file string, dommap bool) (bool, int64, []byte) {
+//
+// Read in title_file, or mmap it if dommap. Unless verbose, only what goes
+// wrong is mentioned.
+//
+func ReadFile(title_file string, dommap, verbose bool) (bool, int64, []byte) {
 	fin, err := os.Open(title_file)
 	if err != nil {
 		fmt.Println(err)
@@ -29,7 +33,9 @@ func ReadFile(title_file string, dommap bool) (bool, int64, []byte) {
 
 	if !dommap {
 		// Default: Load into memory.
-		fmt.Printf("Loading titlecache.dat into Memory . . .\n")
+		if verbose {
+			fmt.Printf("Loading titlecache.dat into Memory . . .\n")
+		}
 		file_blob = make([]byte, file_size, file_size)
 
 		nread, err := fin.Read(file_blob)
@@ -46,3 +52,8 @@ func ReadFile(title_file string, dommap bool) (bool, int64, []byte) {
 	}
 	return true, file_size, file_blob
 }
+
+// Let go of a blob from ReadFile that isn't going to be used any more.
+// Without mmap, the garbage collector takes care of it.
+func Release(file_blob []byte) {
+}
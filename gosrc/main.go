@@ -3,36 +3,40 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"bzreader"
+	"bzsplit"
+	"compress/bzip2"
 	"confparse"
-	"exec"
+	"container/heap"
+	"dataset"
 	"flag"
 	"fmt"
 	"http"
-	"loadfile"
+	"io"
+	"io/ioutil"
+	"json"
 	"os"
 	"path/filepath"
+	"rand"
 	"regexp"
 	"runtime"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"template"
+	"textindex"
+	"textnorm"
 	"time"
 	"unicode"
 	"utf8"
 	"wiki2html"
 )
 
-// current db name, if extant.
-var curdbname string
-
 // Current cache version.
-var current_cache_version = 4
-
-// Current bzwikipedia.dat info
-var dat map[string]string
+var current_cache_version = dataset.Version
 
 // global config variable
 var conf = map[string]string{
@@ -41,16 +45,37 @@ var conf = map[string]string{
 	"namespace_file":         "namespace.conf",
 	"data_dir":               "pdata",
 	"title_file":             "pdata/titlecache.dat",
+	"title_index_file":       "pdata/titleindex.dat",
+	"title_format":           "plain",
+	"text_index":             "no",
+	"text_index_file":        "pdata/textindex.dat",
+	"link_counts":            "no",
+	"trigram_index":          "no",
+	"trigram_file":           "pdata/trigrams.dat",
 	"dat_file":               "pdata/bzwikipedia.dat",
 	"web_dir":                "web",
 	"wiki_template":          "web/wiki.html",
 	"search_template":        "web/searchresults.html",
+	"status_template":        "web/status.html",
+	"notfound_template":      "web/notfound.html",
+	"allpages_template":      "web/allpages.html",
 	"cache_type":             "mmap",
 	"search_routines":        "4",
+	"ingest_routines":        "",
+	"ingest_memory":          "256",
 	"search_ignore_rx":       "",
 	"search_max_results":     "100",
 	"recents_file":           "pdata/recent.dat",
 	"recents_count":          "30",
+	"storage_type":           "split",
+	"redirect_mode":          "alias",
+	"block_file":             "pdata/blockindex.dat",
+	"keep_generations":       "2",
+	"disk_budget":            "0",
+	"admin_hosts":            "127.0.0.1,::1",
+	"watch_interval":         "300",
+	"progress_file":          "pdata/progress.json",
+	"progress_interval":      "10",
 }
 
 func basename(fp string) string {
@@ -61,16 +86,9 @@ var searchRoutines = 4
 var searchMaxResults = 100
 var ignoreSearchRx *regexp.Regexp
 
-type searchRange struct{ Start, End int64 }
-
 var recentCount int
 var recentPages []string
 
-var searchRanges []searchRange
-
-const TITLE_DELIM = '\n'
-const RECORD_DELIM = '\x02'
-
 //
 // Go provides a filepath.Base but not a filepath.Dirname ?!
 // Given foo/bar/baz, return foo/bar
@@ -90,6 +108,15 @@ func dirname(fp string) string {
 	return filepath.FromSlash(nfp)
 }
 
+// How big fp is, or 0 if we can't tell.
+func fileSize(fp string) int64 {
+	stat, err := os.Stat(fp)
+	if err != nil {
+		return 0
+	}
+	return stat.Size
+}
+
 //
 // Convert enwiki-20110405-pages-articles.xml into the integer 20110405
 //
@@ -108,9 +135,10 @@ func fileTimestamp(fp string) int {
 
 //
 // Check data_dir for the newest (using filename YYYYMMDD timestamp)
-// *.xml.bz2 file that exists, and return it.
+// *.xml.bz2 file that exists, and return it. If it is a multistream dump
+// with its -index.txt.bz2 next to it, return the index as well.
 //
-func getRecentDb() string {
+func getRecentDb() (string, string) {
 	dbs, _ := filepath.Glob(filepath.Join(conf["drop_dir"], "*.xml.bz2"))
 	recent := ""
 	recentTimestamp := -1
@@ -125,701 +153,2654 @@ func getRecentDb() string {
 			recent = fp
 		}
 	}
-	return recent
+	return recent, multistreamIndex(recent)
 }
 
 //
-// dosplit, docache := needUpdate()
-// If dosplit is true, then call bzip2recover.
-// If docache is true, then the title cache file needs to
-// be regenerated.
+// Given foo-pages-articles-multistream.xml.bz2, look for
+// foo-pages-articles-multistream-index.txt.bz2.
 //
-func needUpdate(recent string) (bool, bool) {
-	olddat, err := confparse.ParseFile(conf["dat_file"])
-	version := 0
-
-	if err == nil {
-		version, err = strconv.Atoi(olddat["version"])
-
-		if err != nil {
-			fmt.Println("Dat file has invalid format.")
-			return true, true
-		}
-
-		if basename(olddat["dbname"]) == basename(recent) {
-			// The .bz2 records exist, but we may need to
-			// regenerate the title cache file.
-			if version < current_cache_version {
-				fmt.Printf("Version of the title cache file is %d.\n", version)
-				fmt.Printf("Wiping cache and replacing with version %d. This will take a while.\n", current_cache_version)
-				time.Sleep(5000000000)
-				return false, true
-			}
-			return false, false
-		}
-	} else {
-		fmt.Printf("Unable to open %v: %v\n", conf["dat_file"], err)
+func multistreamIndex(recent string) string {
+	if !strings.Contains(basename(recent), "multistream") {
+		return ""
+	}
+	index := strings.Replace(recent, ".xml.bz2", "-index.txt.bz2", 1)
+	if _, err := os.Stat(index); err != nil {
+		fmt.Printf("%v looks like a multistream dump, but there is no %v\n",
+			recent, index)
+		return ""
 	}
-	return true, true
+	return index
 }
 
+////// Generations
 //
-// Clear out any old rec*.xml.bz2 or titlecache.txt files
+// Each dump is split and indexed into its own directory under data_dir, its
+// generation, named after the dump and the storage type, e.g.
+// pdata/enwiki-20110405-pages-articles.split/. That way a new dump can be
+// built while the old one is still being served out of its own generation.
 //
-func cleanOldCache() {
-	recs, _ := filepath.Glob(filepath.Join(conf["data_dir"], "rec*.xml.bz2"))
-	tfs, _ := filepath.Glob(conf["title_file"])
-	dfs, _ := filepath.Glob(conf["dat_file"])
+// The files in a generation are named after title_file, block_file and
+// dat_file, and dat_file itself is a copy of the bzwikipedia.dat of the
+// generation currently being served.
+//
+// Datasets built before there were generations have no gendir in their dat
+// file, and keep their files where the config says.
 
-	// If any old record or title cache files exist, give the user an opportunity
-	// to ctrl-c to cancel this.
+func generationDir(recent, storage string) string {
+	name := strings.Replace(basename(recent), ".xml.bz2", "", 1)
+	return filepath.Join(conf["data_dir"], fmt.Sprintf("%v.%v", name, storage))
+}
 
-	if len(recs) > 0 || len(tfs) > 0 || len(dfs) > 0 {
-		fmt.Println("Old record and/or title cache file exist. Removing in 5 seconds ...")
-		time.Sleep(5000000000)
-	}
+// Where the rec files of the dataset described by d live.
+func datasetDir(d map[string]string) string {
+	return dataset.ChunkDir(d, conf["data_dir"])
+}
 
-	if len(recs) > 0 {
-		fmt.Println("Removing old record files . . .")
-		for _, fp := range recs {
-			os.Remove(fp)
-		}
+// How the dataset package should find and read what the config describes.
+func datasetOptions() dataset.Options {
+	return dataset.Options{
+		DataDir:        conf["data_dir"],
+		TitleFile:      conf["title_file"],
+		TitleIndexFile: conf["title_index_file"],
+		BlockFile:      conf["block_file"],
+		TextIndexFile:  conf["text_index_file"],
+		TrigramFile:    conf["trigram_file"],
+		Mmap:           conf["cache_type"] == "mmap",
+		SearchRoutines: searchRoutines,
+		Verbose:        verbose,
 	}
+}
 
-	if len(tfs) > 0 {
-		fmt.Println("Removing old title file . . .")
-		for _, fp := range tfs {
-			os.Remove(fp)
-		}
-	}
+func openChunkSource(d map[string]string) (bzreader.ChunkSource, os.Error) {
+	return dataset.OpenChunks(d, datasetOptions())
+}
 
-	if len(dfs) > 0 {
-		fmt.Println("Removing old dat file . . .")
-		for _, fp := range dfs {
-			os.Remove(fp)
-		}
-	}
+func closeChunkSource(src bzreader.ChunkSource) {
+	dataset.CloseChunks(src)
 }
 
 //
-// Copy the big database into the data/ dir, bzip2recover to split it into
-// rec#####dbname.bz2, and move the big database back to the drop dir.
+// Clear out the rec*.xml.bz2, block index and title cache files of a
+// dataset we no longer serve. The dat file is left alone, since dat_file
+// is pointing at the new dataset by now.
 //
-func splitBz2File(recent string) {
-	// Be user friendly: Alert the user and wait a few seconds."
-	fmt.Println("I will be using bzip2recover to split", recent, "into many smaller files.")
-	time.Sleep(3000000000)
-
-	// Move the recent db over to the data dir since bzip2recover extracts
-	// to the same directory the db exists in, and we don't want to pollute
-	// drop_dir with the rec*.xml.bz2 files.
-	newpath := filepath.Join(conf["data_dir"], basename(recent))
-	err := os.Rename(recent, newpath)
-
-	if err != nil {
-		if e, ok := err.(*os.LinkError); ok && e.Error == os.EXDEV {
-			panic(GracefulError("Your source file must be on the same partition as your target dir. Sorry."))
-		} else {
-			panic(fmt.Sprintf("rename: %T %#v\n", err, err))
-		}
-	}
-
-	// Make sure that we move it _back_ to drop dir, no matter what happens.
-	defer os.Rename(newpath, recent)
-
-	args := []string{"bzip2recover", newpath}
-
-	executable, patherr := exec.LookPath("bzip2recover")
-	if patherr != nil {
-		fmt.Println("bzip2recover not found anywhere in your path, making wild guess")
-		executable = "/usr/bin/bzip2recover"
-	}
-
-	environ := os.ProcAttr{
-		Dir:   ".",
-		Env:   os.Environ(),
-		Files: []*os.File{os.Stdin, os.Stdout, os.Stderr},
+func removeDataset(d map[string]string) {
+	if d["gendir"] != "" {
+		fmt.Printf("Removing old generation '%v' . . .\n", d["gendir"])
+		os.RemoveAll(d["gendir"])
+		return
 	}
 
-	bz2recover, err := os.StartProcess(executable, args, &environ)
+	recs, _ := filepath.Glob(filepath.Join(conf["data_dir"], "rec*.xml.bz2"))
+	runs, _ := filepath.Glob(filepath.Join(conf["data_dir"], "titlerun*.tmp"))
 
-	if err != nil {
-		switch t := err.(type) {
-		case *os.PathError:
-			if err.(*os.PathError).Error == os.ENOENT {
-				panic(GracefulError("bzip2recover not found. Giving up."))
-			} else {
-				fmt.Printf("err is: %T: %#v\n", err, err)
-				panic("Unable to run bzip2recover? err is ")
-			}
-		default:
-			fmt.Printf("err is: %T: %#v\n", err, err)
-			panic("Unable to run bzip2recover? err is ")
+	if len(recs) > 0 {
+		fmt.Println("Removing old record files . . .")
+		for _, fp := range recs {
+			os.Remove(fp)
 		}
 	}
-	bz2recover.Wait(0)
-}
+	for _, fp := range runs {
+		os.Remove(fp)
+	}
+	os.Remove(filepath.Join(conf["data_dir"], "checkpoint.dat"))
+	os.Remove(filepath.Join(conf["data_dir"], "checkpoint.carry"))
 
-type TitleData struct {
-	Title string
-	Start int
+	fmt.Println("Removing old block index and title file . . .")
+	os.Remove(conf["block_file"])
+	os.Remove(conf["title_file"])
 }
 
-type tdlist []TitleData
-
-func (tds tdlist) Len() int {
-	return len(tds)
-}
-func (tds tdlist) Less(a, b int) bool {
-	return tds[a].Title < tds[b].Title
-}
-func (tds tdlist) Swap(a, b int) {
-	tds[a], tds[b] = tds[b], tds[a]
-}
-func (tds tdlist) Sort() {
-	sort.Sort(tds)
+//
+// A generation that's been built and kept in data_dir.
+//
+type generation struct {
+	Name string
+	Dir  string
+	Dat  map[string]string
+	// Bytes on disk, and when its dat file was written.
+	Size  int64
+	Mtime int64
 }
 
-type searchlist []string
+type genlist []*generation
 
-func (sl searchlist) Len() int {
-	return len(sl)
-}
-func (sl searchlist) Less(a, b int) bool {
-	x := len(sl[a]) - len(sl[b])
-	if x == 0 {
-		return sl[a] < sl[b]
-	}
-	if x > 0 {
-		return false
-	}
-	return true
+func (gl genlist) Len() int {
+	return len(gl)
 }
-func (sl searchlist) Swap(a, b int) {
-	sl[a], sl[b] = sl[b], sl[a]
+func (gl genlist) Less(a, b int) bool {
+	return gl[a].Mtime > gl[b].Mtime
 }
-func (sl searchlist) Sort() {
-	sort.Sort(sl)
+func (gl genlist) Swap(a, b int) {
+	gl[a], gl[b] = gl[b], gl[a]
 }
 
 //
-// Generate the new title cache file.
+// List the complete generations in data_dir, newest first. Those with a
+// checkpoint in them are still being built, or could yet be resumed, so
+// they're left out unless withBuilding is set.
 //
-func generateNewTitleFile() (string, string) {
-	// Create pdata/bzwikipedia.dat.
-	dat_file_new := fmt.Sprintf("%v.new", conf["dat_file"])
-	dfout, derr := os.OpenFile(dat_file_new, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
-	if derr != nil {
-		fmt.Printf("Unable to create '%v': %v\n", dat_file_new, derr)
-		return "", ""
-	}
-	defer dfout.Close()
-
-	// Create pdata/titlecache.dat.
-	title_file_new := fmt.Sprintf("%v.new", conf["title_file"])
-	fout, err := os.OpenFile(title_file_new, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+func listGenerations(withBuilding bool) []*generation {
+	dirs, err := ioutil.ReadDir(conf["data_dir"])
 	if err != nil {
-		fmt.Printf("Unable to create '%v': %v\n", title_file_new, derr)
-		return "", ""
+		fmt.Printf("Unable to read '%v': %v\n", conf["data_dir"], err)
+		return nil
 	}
-	defer fout.Close()
 
-	// Plop version and dbname into bzwikipedia.dat
-	fmt.Fprintf(dfout, "version:%d\n", current_cache_version)
-	fmt.Fprintf(dfout, "dbname:%v\n", curdbname)
-
-	// Now read through all the bzip files looking for <title> bits.
-	bzr := bzreader.NewBzReader(conf["data_dir"], curdbname, 1)
-
-	// We print a notice every 1000 chunks, just 'cuz it's more user friendly
-	// to show _something_ going on.
-	nextprint := 0
-
-	// For title cache version 3:
-	//
-	// We are using <TITLE_DELIM>titlename<RECORD_DELIM>record, and it is sorted,
-	// case sensitively, for binary searching.
-	//
-	// We are optionally discarding redirects and other titles.
-	// Discarding redirects adds a small amount of complexity since we have
-	// <title>, then a few lines later <redirect may or may not exist. So
-	// we don't add <title> to the array until either A: We see another
-	// <title> without seeing <redirect, or we reach end of file.
-	//
-
-        // We use make() to force this to create an array of approximately
-        // how many items we'll need, so that go isn't constantly reallocating
-        // titleslice. 20 million should do it. (As of now, there are over
-        // 11 million articles, about half of which are redirects, in
-        // pages-articles
-	var titleslice = make([]TitleData, 0, 20000000)
-	for {
-		curindex := bzr.Index
-		if curindex >= nextprint {
-			nextprint = nextprint + 100
-			fmt.Println("Reading chunk", curindex)
-		}
-		bstr, err := bzr.ReadBytes()
-		if err == os.EOF {
-			break
+	gens := genlist{}
+	for _, fi := range dirs {
+		if !fi.IsDirectory() {
+			continue
 		}
+		dir := filepath.Join(conf["data_dir"], fi.Name)
+		datfile := filepath.Join(dir, basename(conf["dat_file"]))
+		stat, err := os.Stat(datfile)
 		if err != nil {
-			fmt.Printf("Error while reading chunk %v: %v\n", bzr.Index, err)
-			panic("Unrecoverable error.")
+			continue
 		}
-
-		// This accounts for both "" and is a quick optimization.
-		if len(bstr) < 10 {
+		d, err := confparse.ParseFile(datfile)
+		if err != nil || d["gendir"] == "" {
 			continue
 		}
-
-		idx := bytes.Index(bstr, []byte("<title>"))
-
-		if idx >= 0 {
-			eidx := bytes.Index(bstr, []byte("</title>"))
-			if eidx < 0 {
-				fmt.Printf("eidx is less than 0 for </title>?\n")
-				fmt.Printf("Index %d:%d\n", curindex, bzr.Index)
-				fmt.Printf("String is: '%s'\n", bstr)
-				panic("Can't find </title> tag - broken bz2?")
-			}
-                        titleslice = append(titleslice, TitleData {
-                              Title: string(bstr[idx+7 : eidx]),
-                              Start: curindex,
-                            })
+		if _, err := os.Stat(filepath.Join(dir, "checkpoint.dat")); err == nil && !withBuilding {
+			continue
 		}
-	}
-
-	tdlist(titleslice).Sort()
 
-	for _, i := range titleslice {
-		fmt.Fprintf(fout, "%c%s%c%d", TITLE_DELIM, i.Title, RECORD_DELIM, i.Start)
+		g := &generation{Name: fi.Name, Dir: d["gendir"], Dat: d, Mtime: stat.Mtime_ns}
+		files, _ := ioutil.ReadDir(dir)
+		for _, f := range files {
+			g.Size += f.Size
+		}
+		gens = append(gens, g)
 	}
-
-	fmt.Fprintf(dfout, "rcount:%v\n", len(titleslice))
-
-	return title_file_new, dat_file_new
+	sort.Sort(gens)
+	return gens
 }
 
-////// Title file format: Version 2
-// <TITLE_DELIM>title<RECORD_DELIM>startsegment
-
-////// bzwikipedia.dat file format:
-// version:2
-// dbname:enwiki-20110405-pages-articles.xml.bz2
-// rcount:12345
-// (rcount being record count.)
-
 //
-// Check if any updates to the cached files are needed, and perform
-// them if necessary.
+// Remove old generations beyond the newest keep_generations, and then the
+// oldest of what's left while they take up more than disk_budget MB. The
+// one being served is never removed.
 //
-func performUpdates() bool {
-	fmt.Printf("Checking for new .xml.bz2 files in '%v/'.\n", conf["drop_dir"])
-	recent := getRecentDb()
-	if recent == "" {
-		fmt.Printf("No available database exists in '%v/'.\n", conf["drop_dir"])
-		return false
+func pruneGenerations(active string) {
+	keep, err := strconv.Atoi(conf["keep_generations"])
+	if err != nil || keep < 1 {
+		fmt.Printf("keep_generations: Unable to use '%v', using 1.\n", conf["keep_generations"])
+		keep = 1
 	}
-	fmt.Println("Latest DB:", recent)
-
-	dosplit, docache := needUpdate(recent)
-
-	if !docache {
-		fmt.Println("Cache update not required.")
-		return true
+	budget, err := strconv.Atoi64(conf["disk_budget"])
+	if err != nil {
+		fmt.Printf("disk_budget: Unable to use '%v', using 0.\n", conf["disk_budget"])
+		budget = 0
 	}
+	budget *= 1024 * 1024
 
-	if dosplit {
-		// Clean out old files if we need 'em to be.
-		cleanOldCache()
+	gens := listGenerations(false)
+	total := int64(0)
+	for _, g := range gens {
+		total += g.Size
+	}
 
-		// Turn the big old .xml.bz2 into a bunch of smaller .xml.bz2s
-		splitBz2File(recent)
+	// The active one takes up one of the slots.
+	kept := []*generation{}
+	slots := keep - 1
+	for _, g := range gens {
+		if g.Dir == active {
+			continue
+		}
+		if slots > 0 {
+			slots--
+			kept = append(kept, g)
+			continue
+		}
+		removeDataset(g.Dat)
+		total -= g.Size
 	}
 
-	curdbname = basename(recent)
+	for i := len(kept) - 1; i >= 0 && budget > 0 && total > budget; i-- {
+		fmt.Printf("Over disk_budget by %d MB.\n", (total-budget)/(1024*1024))
+		removeDataset(kept[i].Dat)
+		total -= kept[i].Size
+	}
+}
 
-	// Generate a new title file and dat file
-	newtitlefile, newdatfile := generateNewTitleFile()
+//
+// Building the generation for one dump.
+//
+type ingest struct {
+	recent, index, storage string
+	// The generation directory everything is built in.
+	dir string
+	// If false, the chunks are already there and only the title cache
+	// needs to be regenerated.
+	dosplit bool
+	// If true, there's nothing left to do but switch over to it.
+	built  bool
+	chunks bzreader.ChunkSource
+	// Builds the text index as the chunks are scanned, if we want one.
+	text *textindex.Builder
+	// Whether to count the links to each page while we're at it.
+	links bool
+}
 
-	// Rename them to the actual title and dat file
-	os.Rename(newtitlefile, conf["title_file"])
-	os.Rename(newdatfile, conf["dat_file"])
+// Where the title_file, block_file or dat_file being built goes.
+func (in *ingest) file(key string) string {
+	return filepath.Join(in.dir, basename(conf[key]))
+}
 
-	// We have now completed pre-processing! Yay!
-	// Let's celebrate by restarting to clear out memory.
-	panic(RestartSignal("Performing a full restart for efficiency."))
-	// No 'return true' required because we never get here
+// What the dat file being built will say, as far as openChunkSource cares.
+func (in *ingest) dat() map[string]string {
+	return map[string]string{
+		"dbname":  basename(in.recent),
+		"dbpath":  in.recent,
+		"storage": in.storage,
+		"gendir":  in.dir,
+	}
 }
 
-// Now we load the title cache file. We read it in as one huge lump.
-// <TITLE_DELIM>title<RECORD_DELIM>tartsegment
+//
+// How far along an ingest is. Every phase (split, scan, sort and write)
+// reports through progress, so that it all gets printed the same way and
+// can be read back as JSON.
+//
+type Progress struct {
+	Dump  string `json:"dump"`
+	Phase string `json:"phase"`
+	// What Done and Total count: chunks or titles. Total is 0 if we don't
+	// know.
+	Unit  string `json:"unit"`
+	Done  int64  `json:"done"`
+	Total int64  `json:"total"`
+	// How far into the dump we are, for phases that know.
+	Bytes      int64 `json:"bytes"`
+	TotalBytes int64 `json:"total_bytes"`
+	// Units per second this phase, and seconds to go (-1 if unknown).
+	Rate float64 `json:"rate"`
+	Eta  int64   `json:"eta"`
+	// Seconds spent in this phase, and on the whole build.
+	Elapsed      int64 `json:"elapsed"`
+	BuildElapsed int64 `json:"build_elapsed"`
+	Building     bool  `json:"building"`
+}
 
-var record_count int
-var title_blob []byte
-var title_size int64
+type progressTracker struct {
+	sync.Mutex
+	p Progress
+	// When the build and phase started, in ns.
+	buildStart, phaseStart int64
+	// Where the phase was at its first Update, so that resumed phases
+	// don't look impossibly fast.
+	baseDone, baseBytes int64
+	based               bool
+	lastPrint           int64
+}
 
-func loadTitleFile() bool {
-	var derr os.Error
-	dat, derr = confparse.ParseFile(conf["dat_file"])
-	if derr != nil {
-		fmt.Println(derr)
-		return false
-	}
+var progress progressTracker
 
-	curdbname = dat["dbname"]
-	record_count, derr = strconv.Atoi(dat["rcount"])
-	if derr != nil {
-		fmt.Println(derr)
-		return false
+func progressInterval() int64 {
+	secs, err := strconv.Atoi(conf["progress_interval"])
+	if err != nil || secs < 1 {
+		secs = 10
 	}
+	return int64(secs) * 1000000000
+}
 
-	fmt.Printf("DB '%s': Contains %d records.\n", curdbname, record_count)
+// A new build of dump is starting.
+func (pt *progressTracker) Start(dump string) {
+	pt.Lock()
+	defer pt.Unlock()
 
-	var success bool
+	pt.p = Progress{Dump: basename(dump), Building: true, Eta: -1}
+	pt.buildStart = time.Nanoseconds()
+	pt.phaseStart = pt.buildStart
+}
 
-	success, title_size, title_blob = loadfile.ReadFile(conf["title_file"], conf["cache_type"] == "mmap")
-	return success
+//
+// Move on to the next phase, counting unit, of which there are total (0 if
+// unknown) in totalBytes of dump (0 if that doesn't apply).
+//
+func (pt *progressTracker) Phase(phase, unit string, total, totalBytes int64) {
+	pt.Lock()
+	defer pt.Unlock()
+
+	pt.finishPhase()
+	pt.p.Phase = phase
+	pt.p.Unit = unit
+	pt.p.Done = 0
+	pt.p.Total = total
+	pt.p.Bytes = 0
+	pt.p.TotalBytes = totalBytes
+	pt.p.Rate = 0
+	pt.p.Eta = -1
+	pt.phaseStart = time.Nanoseconds()
+	pt.based = false
+	pt.lastPrint = pt.phaseStart
+	pt.report()
 }
 
-// Compare a needle to an entry in the haystack, but do not create
-// a new string just for it.
-func caseCompare(needle, haystack []byte, hptr int64) int {
-	var i int64
-	l := int64(len(needle))
-	for i = 0; i < l; i++ {
-		if needle[i] != haystack[hptr+i] {
-			if needle[i] > haystack[hptr+i] {
-				return 1
-			} else {
-				return -1
-			}
-		}
+//
+// Done units (and bytes of the dump) are through the current phase. The
+// first Update of a phase sets where it picked up from.
+//
+func (pt *progressTracker) Update(done, bytes int64) {
+	pt.Lock()
+	defer pt.Unlock()
+
+	pt.p.Done = done
+	pt.p.Bytes = bytes
+	if !pt.based {
+		pt.baseDone = done
+		pt.baseBytes = bytes
+		pt.based = true
 	}
-	if haystack[hptr+i] == RECORD_DELIM {
-		return 0
+
+	now := time.Nanoseconds()
+	pt.measure(now)
+	if now-pt.lastPrint >= progressInterval() {
+		pt.lastPrint = now
+		pt.report()
 	}
-	return -1
 }
 
-// Binary search within a blob of unequal length strings.
-func findTitleData(name string) (TitleData, bool) {
-	// We limit to 100, just in case.
-	searchesLeft := 100
-	needle := []byte(name)
-
-	min := int64(0)
-	max := int64(title_size)
+// The build is over, one way or another.
+func (pt *progressTracker) End() {
+	pt.Lock()
+	defer pt.Unlock()
+
+	pt.finishPhase()
+	pt.p.Building = false
+	pt.p.Phase = ""
+	pt.measure(time.Nanoseconds())
+	fmt.Printf("Build of %v took %v.\n", pt.p.Dump, formatDuration(pt.p.BuildElapsed))
+	pt.save()
+}
 
-	minlen := int64(len(needle))
+func (pt *progressTracker) Snapshot() Progress {
+	pt.Lock()
+	defer pt.Unlock()
 
-search:
-	for {
-		// Find the halfway point.
-		if searchesLeft <= 0 {
-			break search
-		}
-		searchesLeft -= 1
-		cur := int64(((max - min) / 2) + min)
-		origcur := cur
-		if (title_size - cur) < minlen {
-			break search
-		}
-
-		// Go backwards to look for the TITLE_DELIM that signifies start of
-		// record.
-	record:
-		for {
-			if cur <= min {
-				if cur <= min {
-					// We may be very close, but searching the wrong way. Search forward,
-					// now.
-					cur = origcur
-					for {
-						if cur >= max {
-							break search
-						}
-						if title_blob[cur] == TITLE_DELIM {
-							break record
-						}
-						cur += 1
-					}
-				}
-			}
-			if title_blob[cur] == TITLE_DELIM {
-				break record
-			}
-			cur -= 1
-		}
+	pt.measure(time.Nanoseconds())
+	return pt.p
+}
 
-		if (max - cur) < minlen {
-			break search
-		}
+// Work out the rate, ETA and elapsed times.
+func (pt *progressTracker) measure(now int64) {
+	p := &pt.p
+	p.Elapsed = (now - pt.phaseStart) / 1000000000
+	p.BuildElapsed = (now - pt.buildStart) / 1000000000
+	if !p.Building || p.Phase == "" {
+		return
+	}
 
-		recordStart := cur + 1
-		recordEnd := recordStart + 1
-		for {
-			if title_blob[recordEnd] == RECORD_DELIM {
-				break
-			}
-			recordEnd += 1
-		}
+	secs := float64(now-pt.phaseStart) / 1e9
+	if secs <= 0 {
+		return
+	}
+	p.Rate = float64(p.Done-pt.baseDone) / secs
 
-		// Now we look for the <RECORD_DELIM>###(<TITLE_DELIM>|end) for the index.
-		numStart := recordEnd + 1
-		numEnd := numStart + 1
-		for {
-			if numEnd >= title_size {
-				numEnd = title_size
-				break
-			}
-			if title_blob[numEnd] == TITLE_DELIM {
-				break
-			}
-			numEnd += 1
-		}
+	p.Eta = -1
+	if p.TotalBytes > 0 && p.Bytes > pt.baseBytes {
+		byteRate := float64(p.Bytes-pt.baseBytes) / secs
+		p.Eta = int64(float64(p.TotalBytes-p.Bytes) / byteRate)
+	} else if p.Total > 0 && p.Done <= p.Total && p.Rate > 0 {
+		p.Eta = int64(float64(p.Total-p.Done) / p.Rate)
+	}
+}
 
-		// Now compare
-		ret := caseCompare(needle, title_blob, recordStart)
+// Print where we are, e.g:
+// [scan] 1200/37000 chunks, 3.2%, 12.5 chunks/s, elapsed 1:36, ETA 47:44
+func (p Progress) String() string {
+	if p.Phase == "" {
+		return "Starting"
+	}
+	buff := bytes.NewBufferString("")
+	fmt.Fprintf(buff, "[%v] %d", p.Phase, p.Done)
+	if p.Total > 0 {
+		fmt.Fprintf(buff, "/%d", p.Total)
+	}
+	fmt.Fprintf(buff, " %v", p.Unit)
+	if p.TotalBytes > 0 {
+		fmt.Fprintf(buff, ", %d/%d MB, %.1f%%", p.Bytes/(1024*1024), p.TotalBytes/(1024*1024),
+			float64(p.Bytes)*100/float64(p.TotalBytes))
+	} else if p.Total > 0 {
+		fmt.Fprintf(buff, ", %.1f%%", float64(p.Done)*100/float64(p.Total))
+	}
+	fmt.Fprintf(buff, ", %.1f %v/s, elapsed %v", p.Rate, p.Unit, formatDuration(p.Elapsed))
+	if p.Eta >= 0 {
+		fmt.Fprintf(buff, ", ETA %v", formatDuration(p.Eta))
+	}
+	return buff.String()
+}
 
-		// Did we find it? Did we?
-		if ret == 0 {
-			// We have the title.
-			td := TitleData{}
-			td.Title = string(title_blob[recordStart:recordEnd])
-			td.Start, _ = strconv.Atoi(string(title_blob[numStart:numEnd]))
-			return td, true
-		}
+func (pt *progressTracker) report() {
+	fmt.Println(pt.p.String())
+	pt.save()
+}
 
-		// Nope, let's divide and conquer.
-		if ret > 0 {
-			min = cur
-		} else if ret < 0 {
-			max = cur
-		}
+// Sum up the phase we're leaving: "[scan] Done: 36123 chunks in 43:14"
+func (pt *progressTracker) finishPhase() {
+	if pt.p.Phase == "" {
+		return
 	}
-	return TitleData{}, false
+	pt.measure(time.Nanoseconds())
+	fmt.Printf("[%v] Done: %d %v in %v\n", pt.p.Phase, pt.p.Done, pt.p.Unit,
+		formatDuration(pt.p.Elapsed))
 }
 
-var wholetextrx = regexp.MustCompile("<text[^>]*>(.*)</text>")
-var starttextrx = regexp.MustCompile("<text[^>]*>(.*)")
-var endtextrx = regexp.MustCompile("(.*)</text>")
+// Write the progress out to progress_file, for scripts to watch.
+func (pt *progressTracker) save() {
+	if conf["progress_file"] == "" {
+		return
+	}
+	data, err := json.Marshal(&pt.p)
+	if err != nil {
+		return
+	}
+	fn_new := fmt.Sprintf("%v.new", conf["progress_file"])
+	if ioutil.WriteFile(fn_new, data, 0666) == nil {
+		os.Rename(fn_new, conf["progress_file"])
+	}
+}
 
-func readTitle(td TitleData) string {
-	var str string
-	var err os.Error
+////// checkpoint.dat file format:
+// dbname:enwiki-20110405-pages-articles.xml.bz2
+// storage:split
+// phase:split
+// chunk:1200
+// bit:1234567890
+// runs:3
+// titles:4567890
+// carry:1199
+// textchunk:1199
+//
+// Written to the generation directory every so often during ingest so that
+// it can be resumed if we die. For phase split, chunk is the last chunk
+// split (or indexed) and bit is where it ends in the dump. For phase scan,
+// chunk is the last chunk whose titles are all in the first <runs> titlerun
+// files or checkpoint.held, titles is how many there are in the runs,
+// carry and carryoffset are where the unfinished line in checkpoint.carry
+// starts, and pagechunk and pageoffset are where the <page> starts whose
+// <title> we haven't seen yet. With a text index, the text* keys are what
+// textindex.Builder saved, and textchunk is the chunk it saved them at.
+// Phase done means the generation is built, but not yet being served.
+
+func (in *ingest) checkpointFile() string {
+	return filepath.Join(in.dir, "checkpoint.dat")
+}
 
-	toFind := fmt.Sprintf("<title>%s</title>", td.Title)
+func (in *ingest) carryFile() string {
+	return filepath.Join(in.dir, "checkpoint.carry")
+}
 
-	// Start looking for the title.
-	bzr := bzreader.NewBzReader(conf["data_dir"], curdbname, td.Start)
+func (in *ingest) heldFile() string {
+	return filepath.Join(in.dir, "checkpoint.held")
+}
 
-	toFindb := []byte(toFind)
-	for {
-		bstr, berr := bzr.ReadBytes()
-		if berr != nil {
-			return ""
-		}
-		if bytes.Index(bstr, toFindb) >= 0 {
-			break
-		}
+// Save the title the sorter is holding back from its runs.
+func (in *ingest) saveHeld(ts *titleSorter) os.Error {
+	buff := bytes.NewBuffer(nil)
+	if td, ok := ts.Held(); ok {
+		dataset.WriteTitleRecord(buff, td)
 	}
+	return ioutil.WriteFile(in.heldFile(), buff.Bytes(), 0666)
+}
 
-	toFind = "<text"
-	for {
-		str, err = bzr.ReadString()
-		if err != nil {
-			return ""
-		}
-		if strings.Contains(str, toFind) {
-			break
-		}
+func (in *ingest) saveCheckpoint(cp map[string]string) {
+	keys := []string{}
+	for key := range cp {
+		keys = append(keys, key)
 	}
+	sort.Strings(keys)
 
-	// We found <text> in string. Capture everything after it.
-	// It may contain </text>
-	matches := wholetextrx.FindStringSubmatch(str)
-	if matches != nil {
-		return matches[1]
+	fn := in.checkpointFile()
+	fn_new := fmt.Sprintf("%v.new", fn)
+	fout, err := os.OpenFile(fn_new, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		fmt.Printf("Unable to create '%v': %v\n", fn_new, err)
+		return
 	}
-
-	// Otherwise, it just has <text>
-	buffer := bytes.NewBufferString("")
-
-	matches = starttextrx.FindStringSubmatch(str)
-	if matches != nil {
-		fmt.Fprint(buffer, matches[1])
+	for _, key := range keys {
+		fmt.Fprintf(fout, "%v:%v\n", key, cp[key])
 	}
+	fout.Close()
+	os.Rename(fn_new, fn)
+}
 
-	toFind = "</text>"
-	for {
-		str, err = bzr.ReadString()
-		if err != nil {
-			return ""
-		}
-		if strings.Contains(str, toFind) {
-			break
-		}
-		fmt.Fprint(buffer, str)
+//
+// Return the checkpoint left by an unfinished ingest of this dump, if there
+// is one. Checkpoints for anything else are thrown away.
+//
+func (in *ingest) loadCheckpoint() map[string]string {
+	cp, err := confparse.ParseFile(in.checkpointFile())
+	if err != nil {
+		return nil
 	}
-
-	matches = endtextrx.FindStringSubmatch(str)
-	if matches != nil {
-		fmt.Fprint(buffer, matches[1])
+	if cp["dbname"] != basename(in.recent) || cp["storage"] != in.storage {
+		fmt.Printf("Ignoring checkpoint for '%v' (%v).\n", cp["dbname"], cp["storage"])
+		in.removeCheckpoint()
+		return nil
 	}
-
-	return string(buffer.Bytes())
+	return cp
 }
 
-func getTitle(str string) string {
-	// Turn foo_bar into foo bar. Strip leading and trailing spaces.
-	str = strings.TrimSpace(str)
-	str = strings.Replace(str, "_", " ", -1)
-	return str
+func (in *ingest) removeCheckpoint() {
+	os.Remove(in.checkpointFile())
+	os.Remove(in.carryFile())
+	os.Remove(in.heldFile())
 }
 
-type SearchPage struct {
-	Phrase                            string
-	Results                           string
-	ResultCount, StartingAt, EndingAt int
-	PageNum, PageCount                int
+//
+// Give up on the scan when it went wrong: What it left lying around goes,
+// and the checkpoint goes back to the start of the scan, so the next
+// attempt doesn't build on anything from this one. The chunks themselves
+// are kept, as splitting the dump again takes hours.
+//
+func (in *ingest) abandonScan() {
+	in.removeCheckpoint()
+	for _, pattern := range []string{"*.tmp", "*.new"} {
+		leftovers, _ := filepath.Glob(filepath.Join(in.dir, pattern))
+		for _, fp := range leftovers {
+			os.Remove(fp)
+		}
+	}
+	in.saveCheckpoint(map[string]string{
+		"dbname":  basename(in.recent),
+		"storage": in.storage,
+		"phase":   "scan",
+		"chunk":   "0",
+		"runs":    "0",
+	})
 }
 
-func getTitleFromPos(haystack []byte, pos int) string {
-	var i, end int
-	for i = pos; i > 0 && haystack[i] != TITLE_DELIM; i -= 1 {
+// The last block split or indexed, according to a phase split checkpoint.
+func checkpointBlock(cp map[string]string) bzsplit.Block {
+	if cp == nil || cp["phase"] != "split" {
+		return bzsplit.Block{}
+	}
+	index, err := strconv.Atoi(cp["chunk"])
+	if err != nil {
+		return bzsplit.Block{}
 	}
-	for end = i; end < len(haystack) && haystack[end] != RECORD_DELIM; end++ {
+	end, err := strconv.Atoi64(cp["bit"])
+	if err != nil {
+		return bzsplit.Block{}
 	}
-	return string(haystack[i+1 : end])
+	return bzsplit.Block{Index: index, End: end}
 }
 
-// How we do searches:
-//
-// caseInsensitiveFinds() searches through haystack, which ideally is already
-// properly bounded.
 //
-// First, it turns needle into both an upper case and lower case copy,
-// so it can use both for quick reference. It discards all non-alphanumeric
-// runes from needle so that "cslewis" will match "C. S. Lewis"
+// Split the big database into rec#####dbname.bz2 files in the generation
+// directory, one per bzip2 block. This used to be done by bzip2recover,
+// which is why the files are named the way they are.
 //
-// Searching in the haystack also ignores non-alphanumeric runes.
-func caseInsensitiveFinds(haystack, needle []byte, watchdog chan []string) {
-	results := []string{}[:]
+func (in *ingest) splitBz2File(cp map[string]string) {
+	recent := in.recent
+	// Be user friendly: Alert the user and wait a few seconds."
+	fmt.Println("I will be splitting", recent, "into many smaller files.")
+	time.Sleep(3000000000)
 
-	defer func() {
-		watchdog <- results
-	}()
+	after := checkpointBlock(cp)
+	if after.Index > 0 {
+		fmt.Println("Picking up after chunk", after.Index)
+	}
+	progress.Phase("split", "chunks", 0, fileSize(recent))
+	progress.Update(int64(after.Index), after.End/8)
+
+	count, err := bzsplit.SplitFrom(recent, in.dir, basename(recent), after,
+		func(b bzsplit.Block) {
+			progress.Update(int64(b.Index), b.End/8)
+			if b.Index%100 == 0 {
+				in.saveCheckpoint(map[string]string{
+					"dbname":  basename(recent),
+					"storage": in.storage,
+					"phase":   "split",
+					"chunk":   strconv.Itoa(b.Index),
+					"bit":     strconv.Itoa64(b.End),
+				})
+			}
+		})
 
-	n := len(needle)
-	if n == 0 {
-		return
+	if err != nil {
+		panic(GracefulError(fmt.Sprintf("Unable to split %v: %v", recent, err)))
 	}
+	fmt.Printf("Split %v into %d chunks.\n", recent, count)
+}
 
-	var urunes []int
-	if true {
-		tmp := bytes.ToUpper(needle)
-		urunes = []int{}[:]
-
-		i := 0
-		for j := 0; j < len(tmp); {
-			rune, cnt := utf8.DecodeRune(tmp[j:])
-			j += cnt
-			// Strip out all spaces.
-			if !unicode.IsSpace(rune) {
-				urunes = append(urunes, rune)
-				i += 1
-			}
+//
+// For storage_type index: Rather than splitting the big database, just
+// record where each bzip2 block starts and ends in it, so we can read
+// chunks straight out of the original.
+//
+func (in *ingest) indexBz2File(cp map[string]string) {
+	recent := in.recent
+	fmt.Println("I will be indexing the blocks of", recent)
+
+	// The index is built up in block_file.new as we go, so that a
+	// checkpoint can refer to it.
+	block_file_new := fmt.Sprintf("%v.new", in.file("block_file"))
+
+	after := checkpointBlock(cp)
+	blocks := []bzsplit.Block{}
+	if after.Index > 0 {
+		fmt.Println("Picking up after chunk", after.Index)
+		var err os.Error
+		blocks, err = bzsplit.ReadIndex(block_file_new)
+		if err != nil || len(blocks) < after.Index {
+			panic(GracefulError(fmt.Sprintf("Unable to resume from %v: %v", block_file_new, err)))
 		}
+		blocks = blocks[:after.Index]
 	}
+	progress.Phase("split", "chunks", 0, fileSize(recent))
+	progress.Update(int64(after.Index), after.End/8)
 
-	var lrunes []int
-	if true {
-		tmp := bytes.ToLower(needle)
-		lrunes = []int{}[:]
-
-		i := 0
-		for j := 0; j < len(tmp); {
-			rune, cnt := utf8.DecodeRune(tmp[j:])
-			j += cnt
-			// Strip out all spaces.
-			if !unicode.IsSpace(rune) {
-				lrunes = append(lrunes, rune)
-				i += 1
-			}
-		}
+	err := bzsplit.WriteIndex(block_file_new, blocks)
+	if err != nil {
+		panic(GracefulError(fmt.Sprintf("Unable to write %v: %v", block_file_new, err)))
 	}
-
-	if len(lrunes) < 1 {
+	fout, err := os.OpenFile(block_file_new, os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		panic(GracefulError(fmt.Sprintf("Unable to write %v: %v", block_file_new, err)))
+	}
+	defer fout.Close()
+	bout := bufio.NewWriter(fout)
+
+	count, err := bzsplit.ScanFrom(recent, after, func(b bzsplit.Block) {
+		bzsplit.WriteIndexLine(bout, b)
+		progress.Update(int64(b.Index), b.End/8)
+		if b.Index%100 == 0 {
+			if bout.Flush() == nil {
+				in.saveCheckpoint(map[string]string{
+					"dbname":  basename(recent),
+					"storage": in.storage,
+					"phase":   "split",
+					"chunk":   strconv.Itoa(b.Index),
+					"bit":     strconv.Itoa64(b.End),
+				})
+			}
+		}
+	})
+
+	if err == nil {
+		err = bout.Flush()
+	}
+	if err == nil {
+		err = os.Rename(block_file_new, in.file("block_file"))
+	}
+	if err != nil {
+		panic(GracefulError(fmt.Sprintf("Unable to index %v: %v", recent, err)))
+	}
+	fmt.Printf("Indexed %d chunks in %v.\n", count, recent)
+}
+
+//
+// Multistream dumps come with an index of offset:pageid:title lines, where
+// offset is the byte offset of the bzip2 stream holding the page. Each
+// stream is one of our chunks, so we number them in order of appearance.
+//
+func readMultistreamIndex(index string, fn func(chunk int, offset int64, id int, title string)) os.Error {
+	fin, err := os.Open(index)
+	if err != nil {
+		return err
+	}
+	defer fin.Close()
+
+	bin := bufio.NewReader(bzip2.NewReader(fin))
+	chunk := 0
+	lastOffset := int64(-1)
+	for {
+		line, err := bin.ReadString('\n')
+		fields := strings.SplitN(strings.TrimRight(line, "\r\n"), ":", 3)
+		if len(fields) == 3 {
+			offset, perr := strconv.Atoi64(fields[0])
+			if perr != nil {
+				return fmt.Errorf("%v: Invalid offset in '%v'", index, line)
+			}
+			id, perr := strconv.Atoi(fields[1])
+			if perr != nil {
+				return fmt.Errorf("%v: Invalid page id in '%v'", index, line)
+			}
+			if offset != lastOffset {
+				chunk++
+				lastOffset = offset
+			}
+			fn(chunk, offset, id, fields[2])
+		}
+		if err == os.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//
+// For multistream dumps: Write a block index of the streams, so we can
+// read them straight out of the dump. No splitting or scanning required.
+//
+func (in *ingest) indexMultistream() {
+	recent, index := in.recent, in.index
+	fmt.Println("I will be reading the stream offsets of", recent, "from", index)
+
+	stat, err := os.Stat(recent)
+	if err != nil {
+		panic(GracefulError(fmt.Sprintf("Unable to stat %v: %v", recent, err)))
+	}
+	progress.Phase("split", "chunks", 0, stat.Size)
+
+	blocks := []bzsplit.Block{}
+	err = readMultistreamIndex(index, func(chunk int, offset int64, id int, title string) {
+		if chunk > len(blocks) {
+			progress.Update(int64(chunk), offset)
+			if len(blocks) > 0 {
+				blocks[len(blocks)-1].End = offset * 8
+			}
+			blocks = append(blocks, bzsplit.Block{Index: chunk, Start: offset * 8})
+		}
+	})
+	if len(blocks) > 0 {
+		blocks[len(blocks)-1].End = stat.Size * 8
+	}
+
+	if err == nil {
+		err = bzsplit.WriteIndex(in.file("block_file"), blocks)
+	}
+	if err != nil {
+		panic(GracefulError(fmt.Sprintf("Unable to index %v: %v", recent, err)))
+	}
+	fmt.Printf("Indexed %d chunks in %v.\n", len(blocks), recent)
+}
+
+type tdlist []dataset.TitleData
+
+func (tds tdlist) Len() int {
+	return len(tds)
+}
+func (tds tdlist) Less(a, b int) bool {
+	return tds[a].Title < tds[b].Title
+}
+func (tds tdlist) Swap(a, b int) {
+	tds[a], tds[b] = tds[b], tds[a]
+}
+func (tds tdlist) Sort() {
+	sort.Sort(tds)
+}
+
+//
+// title_format: plain or frontcoded.
+//
+func titleFormat() string {
+	if conf["title_format"] == "frontcoded" {
+		return "frontcoded"
+	}
+	return "plain"
+}
+
+//
+// text_index: Whether to build a full-text index. Multistream dumps never
+// get one, as their titles come from the index without reading the chunks.
+//
+func textIndexWanted(storage string) bool {
+	return conf["text_index"] == "yes" && storage != "multistream"
+}
+
+//
+// link_counts: Whether to count the links to each page, for ranking search
+// results. Like the text index, that takes reading the chunks.
+//
+func linkCountsWanted(storage string) bool {
+	return conf["link_counts"] == "yes" && storage != "multistream"
+}
+
+//
+// trigram_index: Whether to index the trigrams of the titles, for fuzzy
+// search. That only takes the titles, so any dump can have one.
+//
+func trigramsWanted() bool {
+	return conf["trigram_index"] == "yes"
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+//
+// A setting that goes into building the title cache: What the dat file of
+// one says it was, and what it is now. Changing any of them means building
+// the title cache again, though not the chunks.
+//
+type buildSetting struct {
+	name, built, wanted string
+}
+
+func buildSettings(d map[string]string, storage string) []buildSetting {
+	return []buildSetting{
+		{"redirect_mode", d["redirects"], redirectMode()},
+		{"title_format", dataset.Format(d), titleFormat()},
+		{"text_index", yesNo(d["textindex"] == "yes"), yesNo(textIndexWanted(storage))},
+		{"link_counts", yesNo(d["links"] == "yes"), yesNo(linkCountsWanted(storage))},
+		{"trigram_index", yesNo(d["trigrams"] == "yes"), yesNo(trigramsWanted())},
+	}
+}
+
+//
+// Every title goes into the title cache with its search key, worked out
+// once here so that search needn't fold every title every time.
+//
+type keyedTitleWriter struct {
+	tw titleWriter
+}
+
+func (kw keyedTitleWriter) WriteTitle(td dataset.TitleData) {
+	td.Key = textnorm.Key(td.Title)
+	kw.tw.WriteTitle(td)
+}
+
+//
+// Titles go to the trigram index on their way to the title cache, which
+// numbers them in title cache order.
+//
+type trigramWriter struct {
+	tw titleWriter
+	tb *textindex.Builder
+	// The first error writing out a run, after which we stop trying.
+	err os.Error
+}
+
+func (tgw *trigramWriter) WriteTitle(td dataset.TitleData) {
+	tgw.tb.StartPage(td.Title)
+	tgw.tb.AddWords(textindex.Trigrams(td.Key))
+	if tgw.err == nil {
+		_, tgw.err = tgw.tb.MaybeFlush()
+	}
+	tgw.tw.WriteTitle(td)
+}
+
+//
+// Where the sorted titles go: Either straight into a plain title cache, or
+// front coded into blocks.
+//
+type titleWriter interface {
+	WriteTitle(td dataset.TitleData)
+}
+
+type plainTitleWriter struct {
+	w io.Writer
+}
+
+func (pw plainTitleWriter) WriteTitle(td dataset.TitleData) {
+	dataset.WriteTitleRecord(pw.w, td)
+}
+
+//
+// ingest_memory, in bytes.
+//
+func ingestMemory() int64 {
+	mb, err := strconv.Atoi(conf["ingest_memory"])
+	if err != nil || mb < 16 {
+		fmt.Printf("ingest_memory: Unable to use '%v', using 256.\n", conf["ingest_memory"])
+		mb = 256
+	}
+	return int64(mb) * 1024 * 1024
+}
+
+//
+// ingest_memory is shared evenly between the titles and whichever of the
+// text and trigram indexes are being built, so that between them they
+// stay within it.
+//
+func ingestShare(storage string) int64 {
+	parts := int64(1)
+	if textIndexWanted(storage) {
+		parts++
+	}
+	if trigramsWanted() {
+		parts++
+	}
+	return ingestMemory() / parts
+}
+
+//
+// Sorting titles for the title cache without holding them all in memory.
+//
+// Titles are collected until they take up about budget bytes, then
+// sorted and written out to a run file in dir. At the end, all the runs
+// are merged together into the title cache.
+//
+// The last title added always stays in memory, since we may yet find out
+// that it's a redirect.
+//
+type titleSorter struct {
+	dir    string
+	budget int64
+	used   int64
+	titles []dataset.TitleData
+	runs   []string
+	// How many titles there are in all, including those in runs.
+	count int64
+	// redirect_mode drop: Forget about redirects altogether.
+	dropRedirects bool
+	// Namespace numbers by name, for titles without an <ns>.
+	namespaces map[string]int
+	// The links to each title since the last run. They go into the runs
+	// as records of their own, with a Start of linkStart, and are added
+	// up when the runs are merged.
+	links map[string]int
+	// Where the <page> whose title we're waiting for starts, and whether
+	// the last title's page is still going.
+	pageChunk  int
+	pageOffset int64
+	open       bool
+}
+
+// A rough guess at what each dataset.TitleData costs us beyond its title, including
+// the spare capacity that append leaves lying around.
+const titleOverhead = 64
+
+func newTitleSorter(dir string, budget int64) *titleSorter {
+	return &titleSorter{
+		dir:           dir,
+		budget:        budget,
+		dropRedirects: redirectMode() == "drop",
+		namespaces:    map[string]int{},
+		links:         map[string]int{},
+	}
+}
+
+// What marks a record in a run as a link count rather than a page.
+const linkStart = -1
+
+//
+// Some pages link to these titles.
+//
+func (ts *titleSorter) AddLinks(titles []string) {
+	for _, title := range titles {
+		if _, ok := ts.links[title]; !ok {
+			ts.used += int64(len(title)) + titleOverhead
+		}
+		ts.links[title]++
+	}
+}
+
+// Put the link counts in with the titles, to be sorted along with them.
+func (ts *titleSorter) addLinkRecords() {
+	for title, links := range ts.links {
+		ts.titles = append(ts.titles, dataset.TitleData{Title: title, Start: linkStart, Links: links})
+	}
+	ts.links = map[string]int{}
+}
+
+func (ts *titleSorter) Add(td dataset.TitleData) {
+	ts.titles = append(ts.titles, td)
+	ts.used += int64(len(td.Title)+len(td.Redirect)) + titleOverhead
+	ts.count++
+}
+
+//
+// The last title added turns out to be a redirect to target.
+//
+func (ts *titleSorter) SetRedirect(target string) {
+	last := len(ts.titles) - 1
+	if last < 0 {
+		return
+	}
+	if ts.dropRedirects {
+		ts.used -= int64(len(ts.titles[last].Title)) + titleOverhead
+		ts.titles = ts.titles[:last]
+		ts.count--
+		return
+	}
+	ts.titles[last].Redirect = target
+	ts.used += int64(len(target))
+}
+
+//
+// The title being held back from the runs, if there is one, for
+// checkpointing along with them.
+//
+func (ts *titleSorter) Held() (dataset.TitleData, bool) {
+	if len(ts.titles) == 0 {
+		return dataset.TitleData{}, false
+	}
+	return ts.titles[len(ts.titles)-1], true
+}
+
+//
+// Write out a run if we're over budget. Returns true if it did. This is
+// left to the caller so that runs end somewhere it can checkpoint.
+//
+func (ts *titleSorter) MaybeFlush() bool {
+	if ts.used < ts.budget || len(ts.titles) < 2 {
+		return false
+	}
+	ts.flush(true)
+	return true
+}
+
+//
+// Pick up the runs written before an ingest was interrupted, holding count
+// titles between them, and the title that was held back from them.
+//
+func (ts *titleSorter) Resume(runs int, count int64, held []byte) {
+	for i := 0; i < runs; i++ {
+		ts.runs = append(ts.runs, ts.runFileName(i))
+	}
+	ts.count = count
+	if td, ok := dataset.ParseTitleRecord(bytes.TrimLeft(held, string(dataset.TITLE_DELIM))); ok {
+		ts.Add(td)
+		ts.open = true
+	}
+}
+
+func (ts *titleSorter) runFileName(n int) string {
+	return filepath.Join(ts.dir, fmt.Sprintf("titlerun%04d.tmp", n))
+}
+
+// Sort what we have and write it out as a run, holding back the last title
+// if keepLast is set.
+func (ts *titleSorter) flush(keepLast bool) {
+	var held []dataset.TitleData
+	if keepLast && len(ts.titles) > 0 {
+		held = append(held, ts.titles[len(ts.titles)-1])
+		ts.titles = ts.titles[:len(ts.titles)-1]
+	}
+	ts.addLinkRecords()
+	tdlist(ts.titles).Sort()
+
+	fn := ts.runFileName(len(ts.runs))
+	fout, err := os.OpenFile(fn, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		fmt.Printf("Unable to create '%v': %v\n", fn, err)
+		panic("Unrecoverable error.")
+	}
+	bout := bufio.NewWriter(fout)
+	for _, td := range ts.titles {
+		dataset.WriteTitleRecord(bout, td)
+	}
+	err = bout.Flush()
+	fout.Close()
+	if err != nil {
+		fmt.Printf("Unable to write '%v': %v\n", fn, err)
+		panic("Unrecoverable error.")
+	}
+
+	fmt.Printf("Wrote %d titles to %v\n", len(ts.titles), fn)
+	ts.runs = append(ts.runs, fn)
+	ts.titles = held
+	ts.used = 0
+	for _, td := range held {
+		ts.used += int64(len(td.Title)+len(td.Redirect)) + titleOverhead
+	}
+	runtime.GC()
+}
+
+// One run being merged, and the title at its head.
+type runReader struct {
+	fin  *os.File
+	bin  *bufio.Reader
+	head dataset.TitleData
+}
+
+func (rr *runReader) Next() bool {
+	rec, err := rr.bin.ReadBytes(dataset.TITLE_DELIM)
+	if len(rec) == 0 && err != nil {
+		return false
+	}
+	td, ok := dataset.ParseTitleRecord(rec)
+	if !ok {
+		fmt.Printf("Broken record in %v: '%s'\n", rr.fin.Name(), rec)
+		panic("Unrecoverable error.")
+	}
+	rr.head = td
+	return true
+}
+
+type runHeap []*runReader
+
+func (rh runHeap) Len() int {
+	return len(rh)
+}
+func (rh runHeap) Less(a, b int) bool {
+	return rh[a].head.Title < rh[b].head.Title
+}
+func (rh runHeap) Swap(a, b int) {
+	rh[a], rh[b] = rh[b], rh[a]
+}
+func (rh *runHeap) Push(x interface{}) {
+	*rh = append(*rh, x.(*runReader))
+}
+func (rh *runHeap) Pop() interface{} {
+	old := *rh
+	x := old[len(old)-1]
+	*rh = old[:len(old)-1]
+	return x
+}
+
+//
+// Adds up the link counts that come along with the sorted titles, and
+// passes the titles on to tw with them. Link counts for titles that
+// aren't there are dropped.
+//
+type linkCountWriter struct {
+	tw      titleWriter
+	pending dataset.TitleData
+	page    bool
+	count   int
+}
+
+func (lw *linkCountWriter) WriteTitle(td dataset.TitleData) {
+	if td.Title != lw.pending.Title {
+		lw.Flush()
+		lw.pending = dataset.TitleData{Title: td.Title}
+	}
+	if td.Start == linkStart {
+		lw.pending.Links += td.Links
 		return
 	}
+	// The same title twice: Keep them both, as before.
+	if lw.page {
+		lw.Flush()
+		lw.pending.Links = 0
+	}
+	links := lw.pending.Links
+	lw.pending = td
+	lw.pending.Links += links
+	lw.page = true
+}
 
-	lc := lrunes[0]
-	uc := urunes[0]
-	n = len(lrunes)
+func (lw *linkCountWriter) Flush() {
+	if lw.page {
+		lw.tw.WriteTitle(lw.pending)
+		lw.count++
+	}
+	lw.page = false
+}
 
-	maxlen := len(haystack)
+//
+// Write all the titles, sorted, to tw. Returns how many there were.
+//
+func (ts *titleSorter) WriteTo(tw titleWriter) int {
+	progress.Phase("sort", "titles", ts.count, 0)
+
+	lw := &linkCountWriter{tw: tw}
+
+	// Everything fit into memory: No need to go through the disk.
+	if len(ts.runs) == 0 {
+		ts.addLinkRecords()
+		tdlist(ts.titles).Sort()
+		progress.Update(ts.count, 0)
+		progress.Phase("write", "titles", ts.count, 0)
+		for i, td := range ts.titles {
+			lw.WriteTitle(td)
+			if i%10000 == 0 {
+				progress.Update(int64(lw.count), 0)
+			}
+		}
+		lw.Flush()
+		progress.Update(int64(lw.count), 0)
+		ts.titles = nil
+		return lw.count
+	}
+
+	if len(ts.titles) > 0 {
+		ts.flush(false)
+	}
+	progress.Update(ts.count, 0)
+
+	fmt.Printf("Merging %d runs . . .\n", len(ts.runs))
+	progress.Phase("write", "titles", ts.count, 0)
+
+	rh := &runHeap{}
+	for _, fn := range ts.runs {
+		fin, err := os.Open(fn)
+		if err != nil {
+			fmt.Printf("Unable to open '%v': %v\n", fn, err)
+			panic("Unrecoverable error.")
+		}
+		rr := &runReader{fin: fin, bin: bufio.NewReader(fin)}
+		// Skip the TITLE_DELIM that starts the first record.
+		rr.bin.ReadBytes(dataset.TITLE_DELIM)
+		if rr.Next() {
+			heap.Push(rh, rr)
+		} else {
+			fin.Close()
+		}
+	}
+
+	for records := 1; rh.Len() > 0; records++ {
+		rr := heap.Pop(rh).(*runReader)
+		lw.WriteTitle(rr.head)
+		if records%10000 == 0 {
+			progress.Update(int64(lw.count), 0)
+		}
+		if rr.Next() {
+			heap.Push(rh, rr)
+		} else {
+			rr.fin.Close()
+		}
+	}
+	lw.Flush()
+
+	progress.Update(int64(lw.count), 0)
+
+	for _, fn := range ts.runs {
+		os.Remove(fn)
+	}
+	ts.runs = nil
+	return lw.count
+}
+
+//
+// Generate the new title cache file. Returns the files written, each named
+// what it should be with .new on the end, the dat file last. nil if that
+// didn't work out.
+//
+func (in *ingest) generateNewTitleFile(cp map[string]string) []string {
+	// Create bzwikipedia.dat.
+	dat_file_new := fmt.Sprintf("%v.new", in.file("dat_file"))
+	dfout, derr := os.OpenFile(dat_file_new, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if derr != nil {
+		fmt.Printf("Unable to create '%v': %v\n", dat_file_new, derr)
+		return nil
+	}
+	defer dfout.Close()
+
+	// Create titlecache.dat.
+	title_file_new := fmt.Sprintf("%v.new", in.file("title_file"))
+	fout, err := os.OpenFile(title_file_new, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		fmt.Printf("Unable to create '%v': %v\n", title_file_new, err)
+		return nil
+	}
+	defer fout.Close()
+	bout := bufio.NewWriter(fout)
+
+	// Plop version, dbname and where to find it into bzwikipedia.dat
+	fmt.Fprintf(dfout, "version:%d\n", current_cache_version)
+	fmt.Fprintf(dfout, "dbname:%v\n", basename(in.recent))
+	fmt.Fprintf(dfout, "dbpath:%v\n", in.recent)
+	fmt.Fprintf(dfout, "storage:%v\n", in.storage)
+	fmt.Fprintf(dfout, "redirects:%v\n", redirectMode())
+	fmt.Fprintf(dfout, "format:%v\n", titleFormat())
+	if textIndexWanted(in.storage) {
+		fmt.Fprintf(dfout, "textindex:yes\n")
+	}
+	if linkCountsWanted(in.storage) {
+		fmt.Fprintf(dfout, "links:yes\n")
+	}
+	if trigramsWanted() {
+		fmt.Fprintf(dfout, "trigrams:yes\n")
+	}
+	fmt.Fprintf(dfout, "gendir:%v\n", in.dir)
+	if stat, err := os.Stat(in.recent); err == nil {
+		fmt.Fprintf(dfout, "dbsize:%d\n", stat.Size)
+		fmt.Fprintf(dfout, "dbmtime:%d\n", stat.Mtime_ns)
+	}
+
+	share := ingestShare(in.storage)
+	ts := newTitleSorter(in.dir, share)
+	// Multistream dumps have the <siteinfo> in a stream of its own, before
+	// the first chunk.
+	header := in.chunks
+	if bs, ok := in.chunks.(*bzreader.BlockSource); ok && in.storage == "multistream" {
+		header = bs.Header()
+	}
+	namespaces := readNamespaces(header)
+	if len(namespaces) == 0 {
+		fmt.Printf("Unable to find the namespaces in the <siteinfo> of %v.\n", in.recent)
+		return nil
+	}
+	for _, ns := range namespaces {
+		fmt.Fprintf(dfout, "ns%d:%v\n", ns.Key, ns.Name)
+		fmt.Fprintf(dfout, "nscase%d:%v\n", ns.Key, ns.Case)
+		if ns.Name != "" {
+			ts.namespaces[ns.Name] = ns.Key
+		}
+	}
+
+	if in.storage == "multistream" && conf["text_index"] == "yes" {
+		fmt.Println("Multistream dumps don't get a text index, going without.")
+	}
+	if in.storage == "multistream" && conf["link_counts"] == "yes" {
+		fmt.Println("Multistream dumps don't get link counts, going without.")
+	}
+	// Pages scanned before text_index or link_counts was turned on would
+	// be missing, so start the scan over. So too if the text index was
+	// saved at some other chunk than the titles: Picking up from there
+	// would number pages twice, or not at all.
+	if cp != nil && cp["phase"] == "scan" && cp["chunk"] != "0" &&
+		((textIndexWanted(in.storage) && cp["textchunk"] != cp["chunk"]) ||
+			(linkCountsWanted(in.storage) && cp["links"] != "yes")) {
+		fmt.Println("Scanning from the start, as the checkpoint lacks the text index or link counts.")
+		cp = nil
+	}
+	in.links = linkCountsWanted(in.storage)
+	if textIndexWanted(in.storage) {
+		var textcp map[string]string
+		if cp != nil && cp["phase"] == "scan" {
+			textcp = cp
+		}
+		in.text, err = textindex.NewBuilder(in.dir, "text", share, textcp)
+		if err != nil {
+			fmt.Printf("Unable to start the text index: %v\n", err)
+			return nil
+		}
+	}
+
+	if in.storage == "multistream" {
+		readIndexTitles(in.index, ts)
+	} else {
+		if err := in.scanChunkTitles(ts, cp); err != nil {
+			fmt.Println(err)
+			in.abandonScan()
+			return nil
+		}
+	}
+
+	written := []string{title_file_new}
+	if in.text != nil {
+		text_file_new := fmt.Sprintf("%v.new", in.file("text_index_file"))
+		fmt.Println("Writing the text index.")
+		docs, err := in.text.WriteTo(text_file_new)
+		if err != nil {
+			fmt.Printf("Unable to write '%v': %v\n", text_file_new, err)
+			return nil
+		}
+		fmt.Printf("Indexed the text of %d pages.\n", docs)
+		written = append(written, text_file_new)
+	}
+
+	var tw titleWriter
+	var fw *dataset.FrontCodedWriter
+	if titleFormat() == "frontcoded" {
+		fw = dataset.NewFrontCodedWriter(bout)
+		tw = fw
+	} else {
+		tw = plainTitleWriter{bout}
+	}
+	var tgw *trigramWriter
+	if trigramsWanted() {
+		tb, err := textindex.NewBuilder(in.dir, "trigram", share, nil)
+		if err != nil {
+			fmt.Printf("Unable to start the trigram index: %v\n", err)
+			return nil
+		}
+		tgw = &trigramWriter{tw: tw, tb: tb}
+		tw = tgw
+	}
+
+	count := ts.WriteTo(keyedTitleWriter{tw})
+	if err = bout.Flush(); err != nil {
+		fmt.Printf("Unable to write '%v': %v\n", title_file_new, err)
+		return nil
+	}
+	if tgw != nil && tgw.err != nil {
+		fmt.Printf("Unable to write the trigram index: %v\n", tgw.err)
+		return nil
+	}
+	if fw != nil {
+		index_file_new := fmt.Sprintf("%v.new", in.file("title_index_file"))
+		if err = dataset.WriteTitleIndex(index_file_new, fw.Blocks); err != nil {
+			fmt.Printf("Unable to write '%v': %v\n", index_file_new, err)
+			return nil
+		}
+		written = append(written, index_file_new)
+	}
+	if tgw != nil {
+		trigram_file_new := fmt.Sprintf("%v.new", in.file("trigram_file"))
+		fmt.Println("Writing the trigram index.")
+		if _, err = tgw.tb.WriteTo(trigram_file_new); err != nil {
+			fmt.Printf("Unable to write '%v': %v\n", trigram_file_new, err)
+			return nil
+		}
+		written = append(written, trigram_file_new)
+	}
+
+	fmt.Fprintf(dfout, "rcount:%v\n", count)
+
+	return append(written, dat_file_new)
+}
+
+//
+// Multistream dumps: The index already has every title, and which stream
+// it is in.
+//
+func readIndexTitles(index string, ts *titleSorter) {
+	fmt.Println("Reading titles from", index)
+	progress.Phase("scan", "titles", 0, 0)
+
+	// The index doesn't say where in its stream a page is, so Offset stays
+	// 0 and ReadTitle looks for it from the start of the stream.
+	err := readMultistreamIndex(index, func(chunk int, offset int64, id int, title string) {
+		ts.Add(dataset.TitleData{
+			Title: dataset.EscapeTitle(title),
+			Start: chunk,
+			Id:    id,
+			Ns:    namespaceOf(title, ts.namespaces),
+		})
+		if ts.count%10000 == 0 {
+			progress.Update(ts.count, 0)
+		}
+		ts.MaybeFlush()
+	})
+	progress.Update(ts.count, 0)
+	if err != nil {
+		fmt.Printf("Error while reading %v: %v\n", index, err)
+		panic("Unrecoverable error.")
+	}
+}
+
+// The value of attr="..." in a tag.
+func attrFromLine(line, attr string) string {
+	idx := strings.Index(line, " "+attr+"=\"")
+	if idx < 0 {
+		return ""
+	}
+	rest := line[idx+len(attr)+3:]
+	if end := strings.Index(rest, "\""); end >= 0 {
+		return rest[:end]
+	}
+	return ""
+}
+
+//
+// Read the <namespace key="14" case="first-letter">Category</namespace>
+// lines out of the <siteinfo> that the first chunk starts with.
+//
+func readNamespaces(src bzreader.ChunkSource) []dataset.Namespace {
+	bzr := bzreader.NewSourceReader(src, 1)
+	defer bzr.Close()
+
+	namespaces := []dataset.Namespace{}
+	for {
+		line, err := bzr.ReadString()
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "<namespace ") {
+			key, kerr := strconv.Atoi(attrFromLine(line, "key"))
+			if kerr == nil {
+				ns := dataset.Namespace{Key: key, Case: attrFromLine(line, "case")}
+				start := strings.Index(line, ">")
+				end := strings.Index(line, "</namespace>")
+				if start >= 0 && end > start {
+					ns.Name = line[start+1 : end]
+				}
+				namespaces = append(namespaces, ns)
+			}
+		}
+		if err != nil || strings.HasPrefix(line, "</siteinfo>") ||
+			strings.HasPrefix(line, "<page>") {
+			break
+		}
+	}
+	return namespaces
+}
+
+//
+// The number of the namespace a title is in, going by its prefix.
+//
+func namespaceOf(title string, byName map[string]int) int {
+	colon := strings.Index(title, ":")
+	if colon <= 0 {
+		return 0
+	}
+	return byName[title[:colon]]
+}
+
+//
+// Pull the target out of a <redirect title="..." /> line. It stays XML
+// escaped, like the titles.
+//
+func redirectFromLine(bstr []byte) (string, bool) {
+	if len(bstr) < 10 {
+		return "", false
+	}
+	idx := bytes.Index(bstr, []byte("<redirect"))
+	if idx < 0 {
+		return "", false
+	}
+	rest := bstr[idx:]
+	tidx := bytes.Index(rest, []byte("title=\""))
+	if tidx < 0 {
+		return "", false
+	}
+	rest = rest[tidx+7:]
+	eidx := bytes.IndexByte(rest, '"')
+	if eidx < 0 {
+		return "", false
+	}
+	return string(rest[:eidx]), true
+}
+
+//
+// Pull the title out of a <title> line. index is only used for complaining.
+//
+func titleFromLine(bstr []byte, index int) (string, bool, os.Error) {
+	// This accounts for both "" and is a quick optimization.
+	if len(bstr) < 10 {
+		return "", false, nil
+	}
+
+	idx := bytes.Index(bstr, []byte("<title>"))
+	if idx < 0 {
+		return "", false, nil
+	}
+
+	eidx := bytes.Index(bstr, []byte("</title>"))
+	if eidx < 0 {
+		return "", false, fmt.Errorf("Can't find </title> tag in chunk %d - broken bz2? Line is: '%s'", index, bstr)
+	}
+	return string(bstr[idx+7 : eidx]), true, nil
+}
+
+//
+// What one chunk has to say about titles. Lines may be split across chunks,
+// so the partial first and last lines are handed back to be stitched
+// together with the neighbouring chunks.
+//
+type chunkTitles struct {
+	Index int
+	// Everything up to and including the first newline.
+	Head []byte
+	// Everything after the last newline, and where it starts.
+	Tail       []byte
+	TailOffset int64
+	// If there is no newline at all, Head is the whole chunk.
+	Whole bool
+	Lines []titleLine
+	// If reading the chunk went wrong, and nothing else is set.
+	Err os.Error
+	// What the pages in between link to, if we're counting links.
+	Links []string
+}
+
+// The lines of a page we care about.
+const (
+	pageLine = iota
+	titleTagLine
+	nsLine
+	idLine
+	redirectLine
+	wordsLine
+)
+
+// A <page> line and where in its chunk it is, or one of the lines after it
+// that tell us about its title.
+type titleLine struct {
+	Kind int
+	Text string
+	// The number in <ns> and <id> lines, or the offset of a <page> line.
+	Num int64
+	// For the text index: The words in the lines of text since the last
+	// line above.
+	Words []string
+}
+
+// The number between <tag> and </tag> in a line that starts with <tag>.
+func numberFromLine(bstr, tag []byte) (int64, bool) {
+	if !bytes.HasPrefix(bstr, tag) {
+		return 0, false
+	}
+	bstr = bstr[len(tag):]
+	end := bytes.IndexByte(bstr, '<')
+	if end < 0 {
+		return 0, false
+	}
+	num, err := strconv.Atoi64(string(bstr[:end]))
+	return num, err == nil
+}
+
+//
+// Look at one line of the dump, which starts offset bytes into its chunk.
+// Anything inside <text> has its < escaped, so a line that starts with a
+// tag is always markup.
+//
+func parseTitleLine(bstr []byte, index int, offset int64) (titleLine, bool, os.Error) {
+	bstr = bytes.TrimLeft(bstr, " \t")
+	if len(bstr) == 0 || bstr[0] != '<' {
+		return titleLine{}, false, nil
+	}
+	if bytes.HasPrefix(bstr, []byte("<page>")) {
+		return titleLine{Kind: pageLine, Num: offset}, true, nil
+	}
+	if num, ok := numberFromLine(bstr, []byte("<ns>")); ok {
+		return titleLine{Kind: nsLine, Num: num}, true, nil
+	}
+	if num, ok := numberFromLine(bstr, []byte("<id>")); ok {
+		return titleLine{Kind: idLine, Num: num}, true, nil
+	}
+	title, ok, err := titleFromLine(bstr, index)
+	if err != nil {
+		return titleLine{}, false, err
+	}
+	if ok {
+		return titleLine{Kind: titleTagLine, Text: title}, true, nil
+	}
+	if target, ok := redirectFromLine(bstr); ok {
+		return titleLine{Kind: redirectLine, Text: target}, true, nil
+	}
+	return titleLine{}, false, nil
+}
+
+//
+// The part of a line of the dump that's what the page says, if any. Lines
+// starting with a tag are markup, bar the first and last lines of <text>.
+//
+func textOfLine(bstr []byte) []byte {
+	trimmed := bytes.TrimLeft(bstr, " \t")
+	if len(trimmed) > 0 && trimmed[0] == '<' {
+		if !bytes.HasPrefix(trimmed, []byte("<text")) {
+			return nil
+		}
+		gt := bytes.IndexByte(trimmed, '>')
+		if gt < 0 {
+			return nil
+		}
+		bstr = trimmed[gt+1:]
+	}
+	if end := bytes.Index(bstr, []byte("</text>")); end >= 0 {
+		bstr = bstr[:end]
+	}
+	return bstr
+}
+
+//
+// The titles a bit of wikitext links to: [[target]], [[target|text]] and
+// [[target#section]] all count for target. They're made to look like
+// titles do, with spaces rather than underscores and a capital first
+// letter.
+//
+func linksInText(text []byte) []string {
+	var links []string
+	for {
+		start := bytes.Index(text, []byte("[["))
+		if start < 0 {
+			break
+		}
+		text = text[start+2:]
+		end := bytes.IndexAny(text, "|#]\n")
+		if end < 0 {
+			break
+		}
+		target := strings.Replace(string(text[:end]), "_", " ", -1)
+		target = strings.TrimLeft(strings.TrimSpace(target), ":")
+		if target != "" && !strings.Contains(target, "[") {
+			r, size := utf8.DecodeRuneInString(target)
+			links = append(links, string(unicode.ToUpper(r))+target[size:])
+		}
+		text = text[end:]
+	}
+	return links
+}
+
+//
+// Hand a line to the text index, if we're building one.
+//
+func (in *ingest) indexLine(tl titleLine) {
+	if in.text == nil {
+		return
+	}
+	switch tl.Kind {
+	case titleTagLine:
+		in.text.StartPage(tl.Text)
+	case redirectLine:
+		in.text.SkipPage()
+	case wordsLine:
+		in.text.AddWords(tl.Words)
+	}
+}
+
+//
+// Take in a line found in chunk index. The title goes where its <page>
+// started. Its <ns> and first <id> follow it, before the revision's ids;
+// the number of the namespace is guessed from the title until then, for
+// dumps too old to have <ns>.
+//
+func (ts *titleSorter) AddLine(tl titleLine, index int) {
+	last := len(ts.titles) - 1
+	switch tl.Kind {
+	case pageLine:
+		ts.pageChunk, ts.pageOffset = index, tl.Num
+		ts.open = false
+	case titleTagLine:
+		td := dataset.TitleData{Title: tl.Text, Start: ts.pageChunk, Offset: ts.pageOffset,
+			Ns: namespaceOf(tl.Text, ts.namespaces)}
+		if td.Start == 0 {
+			td.Start, td.Offset = index, 0
+		}
+		ts.Add(td)
+		ts.pageChunk, ts.pageOffset = 0, 0
+		ts.open = true
+	case nsLine:
+		if ts.open && last >= 0 {
+			ts.titles[last].Ns = int(tl.Num)
+		}
+	case idLine:
+		if ts.open && last >= 0 && ts.titles[last].Id == 0 {
+			ts.titles[last].Id = int(tl.Num)
+		}
+	case redirectLine:
+		if ts.open {
+			ts.SetRedirect(tl.Text)
+			ts.open = !ts.dropRedirects
+		}
+	}
+}
+
+func (in *ingest) readChunkTitles(index int) (*chunkTitles, os.Error) {
+	cfin, err := in.chunks.OpenChunk(index)
+	if err != nil {
+		return nil, err
+	}
+	defer cfin.Close()
+
+	data, err := ioutil.ReadAll(bzip2.NewReader(cfin))
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+
+	ct := &chunkTitles{Index: index}
+
+	first := bytes.IndexByte(data, '\n')
+	if first < 0 {
+		ct.Head = data
+		ct.Whole = true
+		return ct, nil
+	}
+	last := bytes.LastIndex(data, []byte{'\n'})
+
+	// Copy these so that the chunk itself can be thrown away.
+	ct.Head = append([]byte{}, data[:first+1]...)
+	ct.Tail = append([]byte{}, data[last+1:]...)
+	ct.TailOffset = int64(last + 1)
+
+	var words []string
+	offset := first + 1
+	for offset <= last {
+		eol := offset + bytes.IndexByte(data[offset:], '\n')
+		line := data[offset : eol+1]
+		tl, ok, err := parseTitleLine(line, index, int64(offset))
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			if len(words) > 0 {
+				ct.Lines = append(ct.Lines, titleLine{Kind: wordsLine, Words: words})
+				words = nil
+			}
+			ct.Lines = append(ct.Lines, tl)
+		} else if in.text != nil || in.links {
+			text := textOfLine(line)
+			if in.text != nil {
+				words = append(words, textnorm.Words(string(text))...)
+			}
+			if in.links {
+				ct.Links = append(ct.Links, linksInText(text)...)
+			}
+		}
+		offset = eol + 1
+	}
+	if len(words) > 0 {
+		ct.Lines = append(ct.Lines, titleLine{Kind: wordsLine, Words: words})
+	}
+	return ct, nil
+}
+
+//
+// readChunkTitles for the scanning goroutines. Whatever goes wrong, even a
+// panic on a broken chunk, comes back in Err: A panic here can't be
+// recovered by whoever started the scan, and would take the whole program
+// down with it.
+//
+func (in *ingest) scanChunk(index int) (ct *chunkTitles) {
+	defer func() {
+		if problem := recover(); problem != nil {
+			ct = &chunkTitles{Index: index, Err: fmt.Errorf("%v", problem)}
+		}
+	}()
+	ct, err := in.readChunkTitles(index)
+	if err != nil {
+		ct = &chunkTitles{Index: index, Err: err}
+	}
+	return ct
+}
+
+//
+// redirect_mode: alias or drop.
+//
+func redirectMode() string {
+	if conf["redirect_mode"] == "drop" {
+		return "drop"
+	}
+	return "alias"
+}
+
+//
+// How many goroutines to scan chunks with: ingest_routines, or
+// search_routines if that isn't set.
+//
+func ingestRoutines() int {
+	setting := conf["ingest_routines"]
+	if setting == "" {
+		setting = conf["search_routines"]
+	}
+	routines, err := strconv.Atoi(setting)
+	if err != nil || routines < 1 || routines > 64 {
+		fmt.Printf("ingest_routines: Unable to use '%v', using 1.\n", setting)
+		return 1
+	}
+	return routines
+}
+
+//
+// Read through all the chunks looking for <title> bits.
+//
+// Each chunk is its own bzip2 stream, so they are decompressed and scanned
+// by a pool of goroutines. The results are put back in chunk order here so
+// that lines split across chunks can be joined up again. As with reading
+// the chunks one after another, a title belongs to the chunk its line
+// starts in.
+//
+// Whenever the titles are written out to a run, we checkpoint. If cp is
+// such a checkpoint, we pick up from there.
+//
+// If a chunk can't be read, or the text index can't be written, the scan
+// stops there and says why.
+//
+func (in *ingest) scanChunkTitles(ts *titleSorter, cp map[string]string) os.Error {
+	total := in.chunks.Chunks()
+	routines := ingestRoutines()
+	// Don't take threads away from the searches if we're serving already.
+	if runtime.GOMAXPROCS(0) < routines {
+		runtime.GOMAXPROCS(routines)
+	}
+
+	// The unfinished last line of the previous chunk(s), and where it
+	// started.
+	var carry []byte
+	carryIndex := 0
+	carryOffset := int64(0)
+
+	first := 1
+	if cp != nil && cp["phase"] == "scan" {
+		last, err1 := strconv.Atoi(cp["chunk"])
+		runs, err2 := strconv.Atoi(cp["runs"])
+		if err1 == nil && err2 == nil && last > 0 {
+			fmt.Printf("Picking up after chunk %d, with %d runs.\n", last, runs)
+			first = last + 1
+			titles, _ := strconv.Atoi64(cp["titles"])
+			held, _ := ioutil.ReadFile(in.heldFile())
+			ts.Resume(runs, titles, held)
+			carryIndex, _ = strconv.Atoi(cp["carry"])
+			carryOffset, _ = strconv.Atoi64(cp["carryoffset"])
+			carry, _ = ioutil.ReadFile(in.carryFile())
+			ts.pageChunk, _ = strconv.Atoi(cp["pagechunk"])
+			ts.pageOffset, _ = strconv.Atoi64(cp["pageoffset"])
+		}
+	}
+
+	fmt.Printf("Scanning %d chunks using %d routines.\n", total, routines)
+	progress.Phase("scan", "chunks", int64(total), 0)
+	progress.Update(int64(first-1), 0)
+
+	todo := make(chan int)
+	done := make(chan *chunkTitles)
+	// Closed if we stop early, so that the goroutines don't wait on us
+	// forever.
+	quit := make(chan bool)
+	defer close(quit)
+
+	for i := 0; i < routines; i++ {
+		go func() {
+			for index := range todo {
+				select {
+				case done <- in.scanChunk(index):
+				case <-quit:
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(todo)
+		for i := first; i <= total; i++ {
+			select {
+			case todo <- i:
+			case <-quit:
+				return
+			}
+		}
+	}()
 
-nextrecord:
-	for i := 0; (i + n) < maxlen; {
-		r, cnt := utf8.DecodeRune(haystack[i:])
-		i += cnt
+	// Chunks that finished ahead of their turn.
+	waiting := make([]*chunkTitles, total+1)
 
-		// Check the first rune against either the lower or upper case needle
-		// rune.
-		if r == lc || r == uc {
-			x := i
-			var s int
+	for next := first; next <= total; {
+		ct := <-done
+		if ct.Err != nil {
+			return fmt.Errorf("Error while reading chunk %v: %v", ct.Index, ct.Err)
+		}
+		waiting[ct.Index] = ct
 
-			// If r is 0-9, then it could be we're looking at a record number in
-			// the haystack. Make sure this doesn't happen.
+		for ; next <= total && waiting[next] != nil; next++ {
+			ct = waiting[next]
+			waiting[next] = nil
+			progress.Update(int64(next), 0)
 
-			if r >= '0' && r <= '9' {
-				// Skip over the next digits
-				ptr := i
-				for ; ptr < maxlen && haystack[ptr] >= '0' && haystack[ptr] <= '9'; ptr++ {
-				}
+			if len(carry) == 0 {
+				carryIndex, carryOffset = ct.Index, 0
+			}
+			line := append(carry, ct.Head...)
 
-				// If it ends at a TITLE_DELIM, then this is not a match.
-				if ptr >= maxlen || haystack[ptr] == TITLE_DELIM {
-					i = ptr
-					continue nextrecord
-				}
+			if ct.Whole {
+				carry = line
+				continue
 			}
 
-			// Check the rest.
-			for s = 1; s < n; s++ {
-				// Skip over all non-alphanumerics.
-				var r, cnt int
-				for {
-					if haystack[x] == RECORD_DELIM || haystack[x] == TITLE_DELIM {
-						break
-					}
-					r, cnt = utf8.DecodeRune(haystack[x:])
-					x += cnt
-					if unicode.IsLetter(r) || unicode.IsDigit(r) {
-						break
-					}
-				}
-				if !(r == urunes[s] || r == lrunes[s]) {
-					break
+			if err := in.addLine(ts, line, carryIndex, carryOffset); err != nil {
+				return err
+			}
+			for _, tl := range ct.Lines {
+				ts.AddLine(tl, ct.Index)
+				in.indexLine(tl)
+			}
+			ts.AddLinks(ct.Links)
+			// The last line carries on into the next chunk, but starts
+			// in this one.
+			carry = ct.Tail
+			carryIndex, carryOffset = ct.Index, ct.TailOffset
+
+			if in.text != nil {
+				if _, err := in.text.MaybeFlush(); err != nil {
+					return err
 				}
 			}
-			if s >= n {
-				cur := getTitleFromPos(haystack, i)
-				if ignoreSearchRx == nil || !ignoreSearchRx.MatchString(cur) {
-					results = append(results, cur)
+			if ts.MaybeFlush() {
+				err := ioutil.WriteFile(in.carryFile(), carry, 0666)
+				if err == nil {
+					err = in.saveHeld(ts)
 				}
-				for {
-					if i > maxlen || haystack[i] == TITLE_DELIM {
-						break
+				if err == nil {
+					cp := map[string]string{
+						"dbname":      basename(in.recent),
+						"storage":     in.storage,
+						"phase":       "scan",
+						"chunk":       strconv.Itoa(next),
+						"runs":        strconv.Itoa(len(ts.runs)),
+						"titles":      strconv.Itoa64(ts.count - int64(len(ts.titles))),
+						"carry":       strconv.Itoa(carryIndex),
+						"carryoffset": strconv.Itoa64(carryOffset),
+						"pagechunk":   strconv.Itoa(ts.pageChunk),
+						"pageoffset":  strconv.Itoa64(ts.pageOffset),
+					}
+					if in.text != nil {
+						textcp, err := in.text.Flush()
+						if err != nil {
+							return err
+						}
+						for k, v := range textcp {
+							cp[k] = v
+						}
+						cp["textchunk"] = strconv.Itoa(next)
 					}
-					i += 1
+					if in.links {
+						cp["links"] = "yes"
+					}
+					in.saveCheckpoint(cp)
 				}
 			}
 		}
 	}
+
+	return in.addLine(ts, carry, carryIndex, carryOffset)
+}
+
+//
+// A line that was split across chunks, put back together.
+//
+func (in *ingest) addLine(ts *titleSorter, line []byte, index int, offset int64) os.Error {
+	tl, ok, err := parseTitleLine(line, index, offset)
+	if err != nil {
+		return err
+	}
+	if ok {
+		ts.AddLine(tl, index)
+		in.indexLine(tl)
+		return nil
+	}
+	if in.text != nil {
+		in.indexLine(titleLine{Kind: wordsLine, Words: textnorm.Words(string(textOfLine(line)))})
+	}
+	if in.links {
+		ts.AddLinks(linksInText(textOfLine(line)))
+	}
+	return nil
+}
+
+////// bzwikipedia.dat file format:
+// version:2
+// dbname:enwiki-20110405-pages-articles.xml.bz2
+// dbpath:drop/enwiki-20110405-pages-articles.xml.bz2
+// storage:split
+// redirects:alias
+// format:plain
+// textindex:yes
+// links:yes
+// trigrams:yes
+// gendir:pdata/enwiki-20110405-pages-articles.split
+// dbsize:7654321098
+// dbmtime:1302000000000000000
+// ns0:
+// nscase0:first-letter
+// ns14:Category
+// nscase14:first-letter
+// rcount:12345
+// (rcount being record count, storage being the storage_type used or
+// multistream, redirects being the redirect_mode used, format being the
+// title_format used (plain if missing), textindex being there if a text
+// index was built, links being there if links to each page were counted,
+// trigrams being there if there's a trigram index, gendir being the
+// generation directory, dbsize and dbmtime being what the dump looked like
+// when it was built, and ns# and nscase# being the name and capitalization
+// of each namespace in the dump.)
+
+//
+// Check if a newer dump than the one described by olddat (nil if there is
+// none) is waiting in drop_dir, or if the title cache needs updating. If
+// so, return the ingest that will build it, otherwise nil.
+//
+func pendingIngest(olddat map[string]string) *ingest {
+	fmt.Printf("Checking for new .xml.bz2 files in '%v/'.\n", conf["drop_dir"])
+	recent, index := getRecentDb()
+	if recent == "" {
+		fmt.Printf("No available database exists in '%v/'.\n", conf["drop_dir"])
+		return nil
+	}
+	fmt.Println("Latest DB:", recent)
+
+	storage := conf["storage_type"]
+	if index != "" {
+		fmt.Println("Multistream index:", index)
+		storage = "multistream"
+	}
+
+	in := &ingest{
+		recent:  recent,
+		index:   index,
+		storage: storage,
+		dir:     generationDir(recent, storage),
+		dosplit: true,
+	}
+
+	if olddat == nil || basename(olddat["dbname"]) != basename(recent) {
+		return in.checkBuilt(olddat)
+	}
+
+	// Switching between split rec files and a block index means starting
+	// over.
+	if dataset.StorageType(olddat) != storage {
+		fmt.Printf("Storage type changed from '%v' to '%v'.\n",
+			dataset.StorageType(olddat), storage)
+		return in.checkBuilt(olddat)
+	}
+
+	version, err := strconv.Atoi(olddat["version"])
+	if err != nil {
+		fmt.Println("Dat file has invalid format.")
+		version = 0
+	}
+	changed := false
+	for _, setting := range buildSettings(olddat, storage) {
+		if setting.built != setting.wanted {
+			changed = true
+			if version >= current_cache_version {
+				fmt.Printf("%v changed from '%v' to '%v'.\n", setting.name, setting.built, setting.wanted)
+			}
+		}
+	}
+	if version >= current_cache_version && !changed {
+		fmt.Println("Cache update not required.")
+		return nil
+	}
+
+	fmt.Printf("Version of the title cache file is %d.\n", version)
+	fmt.Printf("Replacing it with version %d. This will take a while.\n", current_cache_version)
+
+	// The chunks are fine as they are, unless they're from before
+	// generations.
+	if olddat["gendir"] == in.dir {
+		in.dosplit = false
+	}
+	return in
+}
+
+//
+// We may have built this generation before and kept it: Either we died
+// before switching over to it, or someone switched back to an older one
+// since. In the latter case, we leave it be.
+//
+func (in *ingest) checkBuilt(olddat map[string]string) *ingest {
+	// Half built, or built but never switched to: Run sorts that out.
+	if _, err := os.Stat(in.checkpointFile()); err == nil {
+		return in
+	}
+
+	d, err := confparse.ParseFile(in.file("dat_file"))
+	if err != nil || d["dbname"] != basename(in.recent) || dataset.StorageType(d) != in.storage {
+		return in
+	}
+	version, err := strconv.Atoi(d["version"])
+	if err != nil || version < current_cache_version || !sameDump(d, in.recent) {
+		return in
+	}
+	for _, setting := range buildSettings(d, in.storage) {
+		if setting.built != setting.wanted {
+			return in
+		}
+	}
+
+	if olddat != nil {
+		fmt.Printf("'%v' is already built, but '%v' is being served instead.\n",
+			in.dir, olddat["dbname"])
+		fmt.Println("Use /admin/generations to switch to it.")
+		return nil
+	}
+	fmt.Printf("'%v' is already built.\n", in.dir)
+	in.built = true
+	return in
+}
+
+//
+// Whether the dataset d was built from the dump at dbpath as it is now,
+// rather than an earlier file by the same name.
+//
+func sameDump(d map[string]string, dbpath string) bool {
+	stat, err := os.Stat(dbpath)
+	if err != nil {
+		return false
+	}
+	return d["dbsize"] == strconv.Itoa64(stat.Size) &&
+		d["dbmtime"] == strconv.Itoa64(stat.Mtime_ns)
+}
+
+//
+// Build the generation: Split or index the dump if need be, then generate
+// the title cache and dat file. If we died halfway through last time, pick
+// up from the checkpoint.
+//
+func (in *ingest) Run() {
+	if in.built {
+		return
+	}
+
+	cp := in.loadCheckpoint()
+	if cp != nil {
+		fmt.Printf("Resuming where we left off: %v, chunk %v.\n", cp["phase"], cp["chunk"])
+		if cp["phase"] == "done" {
+			return
+		}
+		if cp["phase"] == "scan" {
+			in.dosplit = false
+		}
+	}
+
+	progress.Start(in.recent)
+	defer progress.End()
+
+	if in.dosplit {
+		// Whatever is in the generation directory without a checkpoint is
+		// left over from a build we can't pick up from.
+		if cp == nil {
+			os.RemoveAll(in.dir)
+		}
+		err := os.MkdirAll(in.dir, 0777)
+		if err != nil {
+			panic(GracefulError(fmt.Sprintf("Unable to create %v: %v", in.dir, err)))
+		}
+
+		switch in.storage {
+		case "multistream":
+			// The index file already says where the chunks are.
+			in.indexMultistream()
+		case "index":
+			// Just find where the chunks are in the big old .xml.bz2
+			in.indexBz2File(cp)
+		default:
+			// Turn the big old .xml.bz2 into a bunch of smaller .xml.bz2s
+			in.splitBz2File(cp)
+		}
+
+		// Splitting is done, so don't do it again if we die from here on.
+		cp = map[string]string{
+			"dbname":  basename(in.recent),
+			"storage": in.storage,
+			"phase":   "scan",
+			"chunk":   "0",
+			"runs":    "0",
+		}
+		in.saveCheckpoint(cp)
+	}
+
+	var err os.Error
+	in.chunks, err = openChunkSource(in.dat())
+	if err != nil {
+		panic(GracefulError(fmt.Sprintf("Unable to read chunks of %v: %v", in.recent, err)))
+	}
+	defer closeChunkSource(in.chunks)
+
+	// Generate a new title file and dat file
+	newfiles := in.generateNewTitleFile(cp)
+	if newfiles == nil {
+		panic(GracefulError(fmt.Sprintf("Unable to generate the title cache for %v", in.recent)))
+	}
+
+	// Rename them to the actual title and dat file, the dat file last.
+	for _, fn := range newfiles {
+		os.Rename(fn, fn[:len(fn)-len(".new")])
+	}
+
+	// Built, but not being served yet.
+	in.saveCheckpoint(map[string]string{
+		"dbname":  basename(in.recent),
+		"storage": in.storage,
+		"phase":   "done",
+	})
+}
+
+//
+// Load the generation we've built and start serving it.
+//
+func (in *ingest) Activate() bool {
+	ds, err := openDataset(in.file("dat_file"))
+	if err != nil {
+		fmt.Printf("Unable to load the new dataset: %v\n", err)
+		return false
+	}
+	if !publishDataset(ds, in.file("dat_file")) {
+		return false
+	}
+	in.removeCheckpoint()
+	return true
+}
+
+//
+// Build and switch over to the new generation while the old one is still
+// being served. If anything goes wrong, the old one just keeps on being
+// served, and the checkpoint lets the next attempt pick up from there, or
+// from the start of the scan if scanning the chunks went wrong.
+//
+// Use startIngest rather than calling this directly, so that only one
+// build runs at a time.
+//
+func (in *ingest) Background() {
+	failure := ""
+	defer func() {
+		if problem := recover(); problem != nil {
+			failure = fmt.Sprint(problem)
+			fmt.Printf("Building %v failed: %v\n", in.recent, problem)
+		}
+		ingestStatus.finish(failure)
+	}()
+
+	fmt.Println("Building the new dataset in the background.")
+	in.Run()
+	if !in.Activate() {
+		failure = "Unable to load the new dataset."
+	}
+}
+
+//
+// What the ingest side of things is up to, for /status.
+//
+type ingestState struct {
+	sync.Mutex
+	// The dump being built, if any, and since when.
+	Building string
+	Started  int64
+	// How the last build went.
+	LastDump  string
+	LastError string
+	Finished  int64
+	// When the watcher last looked in drop_dir.
+	Checked int64
+}
+
+var ingestStatus ingestState
+
+//
+// Build in in the background, unless something is being built already.
+//
+func startIngest(in *ingest) bool {
+	ingestStatus.Lock()
+	defer ingestStatus.Unlock()
+
+	if ingestStatus.Building != "" {
+		return false
+	}
+	ingestStatus.Building = in.recent
+	ingestStatus.Started = time.Seconds()
+	go in.Background()
+	return true
+}
+
+func (is *ingestState) finish(failure string) {
+	is.Lock()
+	defer is.Unlock()
+
+	is.LastDump = is.Building
+	is.LastError = failure
+	is.Finished = time.Seconds()
+	is.Building = ""
+}
+
+//
+// Every watch_interval seconds, look in drop_dir for a dump newer than the
+// one being served, and build it once it's done being copied in.
+//
+func watchDropDir() {
+	interval, err := strconv.Atoi(conf["watch_interval"])
+	if err != nil {
+		fmt.Printf("watch_interval: Unable to use '%v', not watching.\n", conf["watch_interval"])
+		return
+	}
+	if interval <= 0 {
+		return
+	}
+
+	// The dump we've seen, how big it was then, and whether we've
+	// already tried building it.
+	seen := ""
+	seenSize := int64(-1)
+	tried := false
+
+	for {
+		time.Sleep(int64(interval) * 1000000000)
+
+		ingestStatus.Lock()
+		ingestStatus.Checked = time.Seconds()
+		busy := ingestStatus.Building != ""
+		ingestStatus.Unlock()
+		if busy {
+			continue
+		}
+
+		recent, _ := getRecentDb()
+		if recent == "" {
+			continue
+		}
+
+		ds := acquireDataset()
+		olddat := ds.Dat
+		releaseDataset()
+
+		if basename(recent) == basename(olddat["dbname"]) ||
+			fileTimestamp(recent) < fileTimestamp(olddat["dbname"]) {
+			continue
+		}
+
+		// Still being copied in, or something new by the same name.
+		stat, err := os.Stat(recent)
+		if err != nil {
+			continue
+		}
+		if recent != seen || stat.Size != seenSize {
+			seen = recent
+			seenSize = stat.Size
+			tried = false
+			continue
+		}
+		if tried {
+			continue
+		}
+		tried = true
+
+		fmt.Printf("Found new dump '%v'.\n", recent)
+		if in := pendingIngest(olddat); in != nil {
+			startIngest(in)
+		}
+	}
+}
+
+func openDataset(datfile string) (*dataset.Dataset, os.Error) {
+	ds, err := dataset.OpenWith(datfile, datasetOptions())
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("DB '%s': Contains %d records.\n", ds.Dbname, ds.Count)
+	return ds, nil
+}
+
+// The dataset being served. Handlers hold datasetLock for reading for as
+// long as they use it, so it can't be closed out from under them.
+var datasetLock sync.RWMutex
+var current *dataset.Dataset
+
+func acquireDataset() *dataset.Dataset {
+	datasetLock.RLock()
+	return current
+}
+
+func releaseDataset() {
+	datasetLock.RUnlock()
+}
+
+// Only one switch at a time.
+var publishLock sync.Mutex
+
+//
+// Switch over to serving ds, loaded from datfile, and point dat_file at it.
+// The dataset served before is closed once nobody is using it any more.
+// Generations past keep_generations or disk_budget are removed, as is a
+// dataset from before generations once we're off it.
+//
+func publishDataset(ds *dataset.Dataset, datfile string) bool {
+	publishLock.Lock()
+	defer publishLock.Unlock()
+
+	if datfile != conf["dat_file"] {
+		data, err := ioutil.ReadFile(datfile)
+		dat_file_new := fmt.Sprintf("%v.new", conf["dat_file"])
+		if err == nil {
+			err = ioutil.WriteFile(dat_file_new, data, 0666)
+		}
+		if err == nil {
+			err = os.Rename(dat_file_new, conf["dat_file"])
+		}
+		if err != nil {
+			fmt.Printf("Unable to update '%v': %v\n", conf["dat_file"], err)
+			ds.Close()
+			return false
+		}
+	}
+
+	datasetLock.Lock()
+	old := current
+	current = ds
+	datasetLock.Unlock()
+
+	fmt.Printf("Now serving '%s'.\n", ds.Dbname)
+
+	if old != nil {
+		old.Close()
+		if old.Dat["gendir"] == "" && datasetDir(old.Dat) != datasetDir(ds.Dat) {
+			removeDataset(old.Dat)
+		}
+		runtime.GC()
+	}
+	if ds.Dat["gendir"] != "" {
+		pruneGenerations(ds.Dat["gendir"])
+	}
+	return true
+}
+
+//
+// Switch to serving the kept generation called name.
+//
+func switchGeneration(name string) os.Error {
+	for _, g := range listGenerations(false) {
+		if g.Name != name {
+			continue
+		}
+		datfile := filepath.Join(g.Dir, basename(conf["dat_file"]))
+		ds, err := openDataset(datfile)
+		if err != nil {
+			return err
+		}
+		if !publishDataset(ds, datfile) {
+			return fmt.Errorf("Unable to switch to '%v'", name)
+		}
+		return nil
+	}
+	return fmt.Errorf("No such generation: '%v'", name)
+}
+
+func getTitle(str string) string {
+	// Turn foo_bar into foo bar. Strip leading and trailing spaces.
+	str = strings.TrimSpace(str)
+	str = strings.Replace(str, "_", " ", -1)
+	return str
+}
+
+//
+// The /wiki/ URL for a title from the title cache.
+//
+func wikiURL(title string) string {
+	title = strings.Replace(dataset.DecodeEntities(title), " ", "_", -1)
+	buff := bytes.NewBufferString("/wiki/")
+	for i := 0; i < len(title); i++ {
+		c := title[i]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+			strings.IndexRune("_-.~:/(),'!*", int(c)) >= 0 {
+			buff.WriteByte(c)
+		} else {
+			fmt.Fprintf(buff, "%%%02X", c)
+		}
+	}
+	return buff.String()
+}
+
+type SearchPage struct {
+	Phrase                            string
+	ResultCount, StartingAt, EndingAt int
+	PageNum, PageCount                int
+	// How many titles were found in each namespace, with links for
+	// narrowing the search down to one, and back to the usual search.
+	Facets []SearchFacet
+	AllURL string
+	// Whether this is a search of what pages say, and the link to one if
+	// there's a text index.
+	Text    bool
+	TextURL string
+	// Whether a phrase search gave up before checking every page.
+	Incomplete bool
+	// Likewise for a search allowing for misspellings, and if nothing was
+	// found, the titles it would have found first.
+	Fuzzy      bool
+	FuzzyURL   string
+	DidYouMean []SearchHit
+	// The results on this page, best first, with how they scored.
+	Hits []SearchHit
+}
+
+//
+// A search result. Alias is the redirect to it that matched, if that
+// matched better than its own title.
+//
+type SearchHit struct {
+	Title, URL string
+	Score      int
+	Links      int
+	Alias      string
+}
+
+func searchHit(hit dataset.Hit) SearchHit {
+	return SearchHit{
+		Title: hit.Title,
+		URL:   wikiURL(hit.Title),
+		Score: hit.Score,
+		Links: hit.Links,
+		Alias: hit.Alias,
+	}
+}
+
+func hitTitles(hits []dataset.Hit) []string {
+	titles := make([]string, len(hits))
+	for i, hit := range hits {
+		titles[i] = hit.Title
+	}
+	return titles
+}
+
+// How many titles "did you mean" offers.
+const didYouMeanCount = 5
+
+type SearchFacet struct {
+	Ns       int
+	Name     string
+	Count    int
+	URL      string
+	Selected bool
+}
+
+//
+// The namespaces an ns=0,14 style parameter asks for. Anything that isn't
+// a number is skipped.
+//
+func parseNamespaceList(param string) []int {
+	namespaces := []int{}
+	for _, field := range strings.Split(param, ",") {
+		if ns, err := strconv.Atoi(strings.TrimSpace(field)); err == nil {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	return namespaces
+}
+
+// What to call a namespace on the search results page.
+func namespaceName(ds *dataset.Dataset, ns int) string {
+	for _, info := range ds.Namespaces {
+		if info.Key == ns && info.Name != "" {
+			return info.Name
+		}
+	}
+	if ns == 0 {
+		return "(Main)"
+	}
+	return fmt.Sprintf("Namespace %d", ns)
+}
+
+//
+// The facets for a search, in namespace order. Those in selected are
+// marked as such.
+//
+func searchFacets(ds *dataset.Dataset, path string, facets map[int]int, selected []int) []SearchFacet {
+	keys := []int{}
+	for ns := range facets {
+		keys = append(keys, ns)
+	}
+	sort.Ints(keys)
+
+	result := []SearchFacet{}
+	for _, ns := range keys {
+		sf := SearchFacet{
+			Ns:    ns,
+			Name:  namespaceName(ds, ns),
+			Count: facets[ns],
+			URL:   fmt.Sprintf("%v?ns=%d", path, ns),
+		}
+		for _, sel := range selected {
+			if sel == ns {
+				sf.Selected = true
+			}
+		}
+		result = append(result, sf)
+	}
+	return result
 }
 
 func markRecent(uri string) {
@@ -909,8 +2890,13 @@ func renderTemplate(tname string, data interface{}) (string, int) {
 }
 
 func searchHandle(w http.ResponseWriter, req *http.Request) {
-	// "/search/"
+	// "/search/", or "/search/?q=" with what to look for in q.
 	pagetitle := getTitle(req.URL.Path[8:])
+	path := req.URL.Path
+	if pagetitle == "" {
+		pagetitle = getTitle(req.FormValue("q"))
+		path = "/search/" + wikiURL(pagetitle)[6:]
+	}
 	startingAt := 0
 
 	startPage := req.FormValue("p")
@@ -929,30 +2915,34 @@ func searchHandle(w http.ResponseWriter, req *http.Request) {
 		}
 	}
 
-	go markRecent(req.URL.Path)
-
-	// A watchdog for the goroutines.
-	watchdog := make(chan []string)
-
-	// Start all goroutine for searching.
-	for i := 0; i < searchRoutines; i++ {
-		go func(s, e int64, w chan []string) {
-			caseInsensitiveFinds(title_blob[s:e], []byte(pagetitle), w)
-		}(searchRanges[i].Start, searchRanges[i].End, watchdog)
-	}
-
-	// First results
-	allresults := <-watchdog
-
-	for i := 1; i < searchRoutines; i++ {
-		additionalresults := <-watchdog
-		allresults = append(allresults, additionalresults...)
+	go markRecent(path)
+
+	ds := acquireDataset()
+	defer releaseDataset()
+
+	// ?ns=0,14 narrows it down to those namespaces.
+	opts := searchOptions()
+	opts.Namespaces = parseNamespaceList(req.FormValue("ns"))
+	// ?mode=text looks for pages that say it rather than ones titled it.
+	// ?text=1 still does too.
+	text := (req.FormValue("mode") == "text" || req.FormValue("text") != "") && ds.Text != nil
+	// ?fuzzy=1 allows for it being misspelt, if there's a trigram index.
+	fuzzy := req.FormValue("fuzzy") != "" && ds.Trigrams != nil && !text
+	var allresults []dataset.Hit
+	var facets map[int]int
+	complete := true
+	if text {
+		var titles []string
+		titles, complete = ds.SearchText(pagetitle, opts)
+		for _, title := range titles {
+			allresults = append(allresults, dataset.Hit{TitleData: dataset.TitleData{Title: title}})
+		}
+	} else if fuzzy {
+		allresults = ds.SearchFuzzy(pagetitle, opts)
+	} else {
+		allresults, facets = ds.SearchHits(pagetitle, opts)
 	}
 
-	// Sort results.
-	//sort.Strings(allresults)
-	searchlist(allresults).Sort()
-
 	// Take the first searchMaxResults
 	p := SearchPage{
 		Phrase:      pagetitle,
@@ -960,9 +2950,30 @@ func searchHandle(w http.ResponseWriter, req *http.Request) {
 		ResultCount: len(allresults),
 		PageNum:     (startingAt / searchMaxResults) + 1,
 		PageCount:   (len(allresults) + (searchMaxResults - 1)) / searchMaxResults,
+		Facets:      searchFacets(ds, path, facets, opts.Namespaces),
+		AllURL:      path,
+		Text:        text,
+		Incomplete:  !complete,
+		Fuzzy:       fuzzy,
+	}
+	if ds.Text != nil && !text {
+		p.TextURL = path + "?mode=text"
+	}
+	if ds.Trigrams != nil && !text && !fuzzy {
+		p.FuzzyURL = path + "?fuzzy=1"
+		// Nothing at all: Perhaps it's misspelt.
+		if len(allresults) == 0 {
+			suggestions := ds.SearchFuzzy(pagetitle, opts)
+			if len(suggestions) > didYouMeanCount {
+				suggestions = suggestions[:didYouMeanCount]
+			}
+			for _, hit := range suggestions {
+				p.DidYouMean = append(p.DidYouMean, searchHit(hit))
+			}
+		}
 	}
 
-	var results []string
+	var results []dataset.Hit
 
 	maxResultsLeft := len(allresults) - startingAt
 	numResults := maxResultsLeft
@@ -980,7 +2991,9 @@ func searchHandle(w http.ResponseWriter, req *http.Request) {
 	}
 	p.EndingAt = startingAt + numResults
 
-	p.Results = strings.Join(results, "|")
+	for _, hit := range results {
+		p.Hits = append(p.Hits, searchHit(hit))
+	}
 
 	page, status := renderTemplate(conf["search_template"], &p)
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
@@ -991,67 +3004,490 @@ func searchHandle(w http.ResponseWriter, req *http.Request) {
 }
 
 type WikiPage struct {
-	Title string
-	Body  string
-	Refs  []string
+	Title             string
+	Body              string
+	Refs              []string
+	RedirectedFrom    string
+	RedirectedFromURL string
+	// Which revision of the page the dump has, XML escaped as in the dump.
+	Revision dataset.Revision
+}
+
+// What the wiki template is given for a page read from the dump.
+func wikiPage(page *dataset.Page) *WikiPage {
+	body, refs := wiki2html.Wiki2HTML(page.Text)
+	wp := &WikiPage{
+		Title:          page.Title,
+		Body:           body,
+		Refs:           refs,
+		RedirectedFrom: page.RedirectedFrom,
+		Revision:       page.Revision,
+	}
+	if wp.RedirectedFrom != "" {
+		wp.RedirectedFromURL = wikiURL(wp.RedirectedFrom)
+	}
+	return wp
+}
+
+//
+// /wiki/<title>?json=1: The wikitext of a page and what's known about it,
+// unescaped.
+//
+type PageJSON struct {
+	Title          string       `json:"title"`
+	Id             int          `json:"id"`
+	Ns             int          `json:"ns"`
+	RedirectedFrom string       `json:"redirected_from"`
+	Revision       RevisionJSON `json:"revision"`
+	Text           string       `json:"text"`
+}
+
+type RevisionJSON struct {
+	Id          int    `json:"id"`
+	Timestamp   string `json:"timestamp"`
+	Contributor string `json:"contributor"`
+	Comment     string `json:"comment"`
+	Minor       bool   `json:"minor"`
+}
+
+func pageJSON(page *dataset.Page) *PageJSON {
+	rev := page.Revision
+	return &PageJSON{
+		Title:          dataset.DecodeEntities(page.Title),
+		Id:             page.Id,
+		Ns:             page.Ns,
+		RedirectedFrom: dataset.DecodeEntities(page.RedirectedFrom),
+		Revision: RevisionJSON{
+			Id:          rev.Id,
+			Timestamp:   rev.Timestamp,
+			Contributor: dataset.DecodeEntities(rev.Contributor),
+			Comment:     dataset.DecodeEntities(rev.Comment),
+			Minor:       rev.Minor,
+		},
+		Text: dataset.DecodeEntities(page.Text),
+	}
+}
+
+// The layout of <timestamp>s in the dump.
+const dumpTimeFormat = "2006-01-02T15:04:05Z"
+
+//
+// Say which revision of a page is being served in the headers, so that
+// even ?raw=1 tells how current it is.
+//
+func revisionHeaders(w http.ResponseWriter, rev dataset.Revision) {
+	if rev.Id != 0 {
+		w.Header().Set("X-Revision-Id", strconv.Itoa(rev.Id))
+	}
+	if rev.Timestamp != "" {
+		w.Header().Set("X-Revision-Timestamp", rev.Timestamp)
+		if t, err := time.Parse(dumpTimeFormat, rev.Timestamp); err == nil {
+			w.Header().Set("Last-Modified", t.Format(http.TimeFormat))
+		}
+	}
+	if rev.Contributor != "" {
+		w.Header().Set("X-Revision-Contributor", dataset.DecodeEntities(rev.Contributor))
+	}
+	if rev.Comment != "" {
+		w.Header().Set("X-Revision-Comment", dataset.DecodeEntities(rev.Comment))
+	}
+}
+
+type TitleLink struct {
+	Title, URL string
+	Redirect   bool
+}
+
+type NotFoundPage struct {
+	Title   string
+	Results []TitleLink
 }
 
 func pageHandle(w http.ResponseWriter, req *http.Request) {
 	// "/wiki/"
-	pagetitle := getTitle(req.URL.Path[6:])
 	doRaw := (req.FormValue("raw") != "")
+	doJSON := (req.FormValue("json") != "")
+
+	ds := acquireDataset()
+	defer releaseDataset()
+
+	pagetitle := ds.Normalize(req.URL.Path[6:])
+	td, ok := ds.FindTitle(pagetitle)
+
+	// Not quite right: Send them to where it is, so that the URL they end
+	// up with is the right one. Not permanently, as where that is can
+	// change with the next dump.
+	if !ok {
+		title, results := ds.LookupFold(pagetitle, searchOptions())
+		if title == "" && len(results) == 0 {
+			// Misspelt, perhaps.
+			results = hitTitles(ds.SearchFuzzy(dataset.DecodeEntities(pagetitle), searchOptions()))
+		}
+		if title == "" {
+			notFound(w, pagetitle, results)
+			return
+		}
+		url := wikiURL(title)
+		if req.URL.RawQuery != "" {
+			url += "?" + req.URL.RawQuery
+		}
+		http.Redirect(w, req, url, http.StatusFound)
+		return
+	}
 
 	go markRecent(req.URL.Path)
 
-	td, ok := findTitleData(pagetitle)
+	// ?redirect=no shows a redirect page itself.
+	var wp *dataset.Page
+	if ok {
+		wp = ds.ReadPage(td, req.FormValue("redirect") != "no")
+		revisionHeaders(w, wp.Revision)
+	}
 
         if ok && doRaw {
                 w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-                text := readTitle(td)
-		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(text)))
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(wp.Text)))
 
 		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(text))
+		w.Write([]byte(wp.Text))
                 return
         }
 
+	if ok && doJSON {
+		data, err := json.Marshal(pageJSON(wp))
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintf(w, "%v\n", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(data)))
+		w.WriteHeader(http.StatusOK)
+		w.Write(data)
+		return
+	}
+
 	if ok {
                 w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		body, refs := wiki2html.Wiki2HTML(readTitle(td))
-		p := WikiPage{
-			Title: pagetitle,
-			Body:  body,
-			Refs:  refs,
-		}
-		page, status := renderTemplate(conf["wiki_template"], &p)
+		page, status := renderTemplate(conf["wiki_template"], wikiPage(wp))
 		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(page)))
 
 		w.WriteHeader(status)
 		w.Write([]byte(page))
-	} else {
-		w.WriteHeader(http.StatusNotFound)
-		fmt.Fprintf(w, "No such Wiki Page")
 	}
 
 }
 
+//
+// There's no such page: Offer what searching for it found instead.
+//
+func notFound(w http.ResponseWriter, title string, results []string) {
+	if len(results) > searchMaxResults {
+		results = results[:searchMaxResults]
+	}
+	p := NotFoundPage{Title: title}
+	for _, result := range results {
+		p.Results = append(p.Results, TitleLink{Title: result, URL: wikiURL(result)})
+	}
+	page, status := renderTemplate(conf["notfound_template"], &p)
+	if status == http.StatusOK {
+		status = http.StatusNotFound
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", len(page)))
+	w.WriteHeader(status)
+	w.Write([]byte(page))
+}
+
+//
+// /random sends you off to a page picked at random, leaving out redirects
+// and whatever search_ignore_rx says to.
+//
+func randomHandle(w http.ResponseWriter, req *http.Request) {
+	ds := acquireDataset()
+	td, ok := ds.Random(ignoreSearchRx)
+	releaseDataset()
+
+	if !ok {
+		notFound(w, "Random page", nil)
+		return
+	}
+	http.Redirect(w, req, wikiURL(td.Title), http.StatusFound)
+}
+
+// How many titles /allpages lists at a time, and how many it'll look
+// through for them, skipping those search_ignore_rx says to.
+const allPagesCount = 200
+const allPagesWalk = 10000
+
+type AllPages struct {
+	From   string
+	Titles []TitleLink
+	// Where the next page starts, if there's one.
+	Next, NextURL string
+}
+
+//
+// /allpages?from=X lists the titles from X on, in order. Those matching
+// search_ignore_rx are left out. Redirects are in, but marked.
+//
+func allPagesHandle(w http.ResponseWriter, req *http.Request) {
+	ds := acquireDataset()
+	from := ""
+	if req.FormValue("from") != "" {
+		from = ds.Normalize(req.FormValue("from"))
+	}
+
+	p := AllPages{From: from}
+	walked := 0
+	for tc := ds.Seek([]byte(from)); tc.Valid(); tc.Next() {
+		title := string(tc.Title())
+		walked++
+		if len(p.Titles) == allPagesCount || walked > allPagesWalk {
+			p.Next = title
+			// The same escaping as /wiki/ URLs.
+			p.NextURL = "/allpages?from=" + wikiURL(title)[6:]
+			break
+		}
+		if ignoreSearchRx != nil && ignoreSearchRx.MatchString(title) {
+			continue
+		}
+		td, _ := tc.Record()
+		p.Titles = append(p.Titles, TitleLink{
+			Title:    title,
+			URL:      wikiURL(title),
+			Redirect: td.Redirect != "",
+		})
+	}
+	releaseDataset()
+
+	page, status := renderTemplate(conf["allpages_template"], &p)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", len(page)))
+	w.WriteHeader(status)
+	w.Write([]byte(page))
+}
+
 func recentHandle(w http.ResponseWriter, req *http.Request) {
 	// "/recent"
 	x := strings.Join(recentPages, "\n")
 	fmt.Fprintf(w, "%v\n", x)
 }
 
-// Prepare the globals needed for fast searching.
 //
-// type searchRange struct { Start, End int }
-// var searchRanges []searchRange
+// Turn a number of seconds into 43:14 or 1:02:03.
+//
+func formatDuration(secs int64) string {
+	if secs < 0 {
+		secs = 0
+	}
+	if secs >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", secs/3600, secs/60%60, secs%60)
+	}
+	return fmt.Sprintf("%d:%02d", secs/60, secs%60)
+}
+
+type StatusPage struct {
+	Dbname    string
+	Records   int
+	Building  string
+	Elapsed   string
+	Progress  string
+	LastDump  string
+	LastError string
+	Finished  string
+	Checked   string
+}
+
+func statusHandle(w http.ResponseWriter, req *http.Request) {
+	// "/status"
+	p := StatusPage{}
+
+	ds := acquireDataset()
+	p.Dbname = ds.Dbname
+	p.Records = ds.Count
+	releaseDataset()
+
+	now := time.Seconds()
+	ingestStatus.Lock()
+	if ingestStatus.Building != "" {
+		p.Building = basename(ingestStatus.Building)
+		p.Elapsed = formatDuration(now - ingestStatus.Started)
+		p.Progress = progress.Snapshot().String()
+	}
+	if ingestStatus.LastDump != "" {
+		p.LastDump = basename(ingestStatus.LastDump)
+		p.LastError = ingestStatus.LastError
+		p.Finished = formatDuration(now-ingestStatus.Finished) + " ago"
+	}
+	if ingestStatus.Checked != 0 {
+		p.Checked = formatDuration(now-ingestStatus.Checked) + " ago"
+	}
+	ingestStatus.Unlock()
+
+	page, status := renderTemplate(conf["status_template"], &p)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", len(page)))
+
+	w.WriteHeader(status)
+	w.Write([]byte(page))
+}
+
+// What /status.json hands out.
+type StatusJSON struct {
+	Dbname    string   `json:"dbname"`
+	Records   int      `json:"records"`
+	Building  string   `json:"building"`
+	Progress  Progress `json:"progress"`
+	LastDump  string   `json:"last_dump"`
+	LastError string   `json:"last_error"`
+}
+
+func statusJSONHandle(w http.ResponseWriter, req *http.Request) {
+	// "/status.json"
+	p := StatusJSON{Progress: progress.Snapshot()}
+
+	ds := acquireDataset()
+	p.Dbname = ds.Dbname
+	p.Records = ds.Count
+	releaseDataset()
+
+	ingestStatus.Lock()
+	p.Building = basename(ingestStatus.Building)
+	p.LastDump = basename(ingestStatus.LastDump)
+	p.LastError = ingestStatus.LastError
+	ingestStatus.Unlock()
+
+	data, err := json.Marshal(&p)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "%v\n", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", len(data)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+//
+// What /api/suggest answers with: The query, and the titles it matched.
+//
+type Suggestions struct {
+	Query  string   `json:"query"`
+	Titles []string `json:"titles"`
+}
+
+// The most suggestions /api/suggest hands out at once.
+const maxSuggestions = 100
+
+//
+// /api/suggest?q=prefix[&limit=N][&fold=1]: The titles starting with what
+// has been typed so far, as JSON, for a search box to offer. fold=1 also
+// finds those that only start with it without regard to case.
+//
+func suggestHandle(w http.ResponseWriter, req *http.Request) {
+	typed := req.FormValue("q")
+	limit, err := strconv.Atoi(req.FormValue("limit"))
+	if err != nil || limit < 1 {
+		limit = 10
+	}
+	if limit > maxSuggestions {
+		limit = maxSuggestions
+	}
+	fold := req.FormValue("fold") != "" && req.FormValue("fold") != "0"
+
+	ds := acquireDataset()
+	opts := searchOptions()
+	opts.Limit, opts.Fold = limit, fold
+	titles := ds.Suggest(typed, opts)
+	releaseDataset()
+
+	p := Suggestions{Query: typed, Titles: make([]string, len(titles))}
+	for i, title := range titles {
+		p.Titles[i] = dataset.DecodeEntities(title)
+	}
+
+	data, err := json.Marshal(&p)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "%v\n", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", len(data)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
 //
-// What this does is pre-split the db ('haystack') into approximately equal
-// portions, bounded by TITLE_DELIM characters and the beginning and end of
-// the titlecache file.
+// Only hosts in admin_hosts get to use /admin/.
 //
-// A setup with a single searchRoutine would have Start = 1 and End = title_size
-func prepSearchRoutines() {
+func adminAllowed(req *http.Request) bool {
+	host := req.RemoteAddr
+	if i := strings.LastIndex(host, ":"); i >= 0 {
+		host = host[:i]
+	}
+	host = strings.Trim(host, "[]")
+	for _, allowed := range strings.Split(conf["admin_hosts"], ",") {
+		if strings.TrimSpace(allowed) == host {
+			return true
+		}
+	}
+	return false
+}
+
+func generationsHandle(w http.ResponseWriter, req *http.Request) {
+	// "/admin/generations"
+	if !adminAllowed(req) {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprintf(w, "Forbidden\n")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	// POST use=<name> switches to that generation. A page elsewhere can
+	// get a browser on an admin host to POST a form here, but not with
+	// headers of its own, so switching needs an X-Bzwikipedia-Admin one.
+	if req.Method == "POST" {
+		if req.Header.Get("X-Bzwikipedia-Admin") == "" {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprintf(w, "Switching needs an X-Bzwikipedia-Admin header\n")
+			return
+		}
+		name := req.FormValue("use")
+		if err := switchGeneration(name); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "%v\n", err)
+			return
+		}
+	}
+
+	ds := acquireDataset()
+	active := ds.Dat["gendir"]
+	releaseDataset()
+
+	// * marks the one being served, + those still being built.
+	for _, g := range listGenerations(true) {
+		mark := " "
+		if g.Dir == active {
+			mark = "*"
+		} else if _, err := os.Stat(filepath.Join(g.Dir, "checkpoint.dat")); err == nil {
+			mark = "+"
+		}
+		fmt.Fprintf(w, "%v %v version:%v rcount:%v size:%dMB\n",
+			mark, g.Name, g.Dat["version"], g.Dat["rcount"], g.Size/(1024*1024))
+	}
+}
+
+// How searches should go, as far as the config says.
+func searchOptions() dataset.SearchOptions {
+	return dataset.SearchOptions{Ignore: ignoreSearchRx}
+}
+
+// Read the search settings from the config.
+func prepSearchConfig() {
 	if conf["search_ignore_rx"] != "" {
 		ignoreSearchRx = regexp.MustCompile(conf["search_ignore_rx"])
 	} else {
@@ -1084,35 +3520,8 @@ func prepSearchRoutines() {
 		}
 	}
 
-	if searchRoutines > 1 {
-		mult := title_size / int64(searchRoutines)
-		searchRanges = make([]searchRange, searchRoutines)
-		ptr := int64(0)
-		for i := 0; i < searchRoutines; i++ {
-			// Start at the end of the last one.
-			searchRanges[i].Start = ptr
-			ptr = mult * int64((i + 1))
-			if ptr >= title_size {
-				ptr = title_size
-			} else {
-				for {
-					if title_blob[ptr] == TITLE_DELIM {
-						break
-					}
-					if ptr < searchRanges[i].Start {
-						fmt.Printf("Something is wrong with your titleCache.dat!\n")
-						panic("Invalid titleCache.dat")
-					}
-					ptr--
-				}
-			}
-			searchRanges[i].End = ptr
-		}
-	} else {
+	if searchRoutines < 1 {
 		searchRoutines = 1
-		searchRanges = make([]searchRange, searchRoutines)
-		searchRanges[0].Start = 0
-		searchRanges[0].End = title_size
 	}
 }
 
@@ -1170,7 +3579,9 @@ func parseConfig(confname string) {
 		return
 	}
 
-	fmt.Printf("Read config file '%s'\n", confname)
+	if verbose {
+		fmt.Printf("Read config file '%s'\n", confname)
+	}
 
 	for key, value := range fromfile {
 		if _, ok := conf[key]; !ok {
@@ -1188,13 +3599,204 @@ func parseNameSpaces(confname string) {
 		return
 	}
 
-	fmt.Printf("Read namespace file '%s'\n", confname)
+	if verbose {
+		fmt.Printf("Read namespace file '%s'\n", confname)
+	}
 
 	wiki2html.ConfigureNameSpaces(fromfile)
 }
 
+//
+// Commands, for getting at the dataset being served from scripts without
+// going through the web server:
+//
+//   bzwikipedia get <title> [-format raw|html|text|json]
+//   bzwikipedia search <phrase> [-ns 0,14] [-text|-fuzzy]
+//   bzwikipedia titles [-prefix <prefix>]
+//   bzwikipedia stats
+//
+// They only read what's in pdata/, so they can be run while the server is.
+//
+var commands = map[string]func(args []string){
+	"get":    getCommand,
+	"search": searchCommand,
+	"titles": titlesCommand,
+	"stats":  statsCommand,
+}
+
+//
+// Parse the flags of a command, which can come before, after or between its
+// other arguments. Returns those other arguments.
+//
+func parseCommandFlags(fs *flag.FlagSet, args []string) []string {
+	rest := []string{}
+	for {
+		fs.Parse(args)
+		args = fs.Args()
+		if len(args) == 0 {
+			return rest
+		}
+		rest = append(rest, args[0])
+		args = args[1:]
+	}
+	return rest
+}
+
+// Open the dataset dat_file says is being served, or give up.
+func commandDataset() *dataset.Dataset {
+	ds, err := dataset.OpenWith(conf["dat_file"], datasetOptions())
+	if err != nil {
+		panic(GracefulError(fmt.Sprintf("Unable to load '%v': %v", conf["dat_file"], err)))
+	}
+	return ds
+}
+
+var htmltagrx = regexp.MustCompile("<[^>]*>")
+var blanklinesrx = regexp.MustCompile("\n[ \t]*\n([ \t]*\n)+")
+
+//
+// The text of a page rendered by wiki2html, without the markup: List items
+// get a "* " and line breaks are kept, and everything else is dropped.
+//
+func htmlToText(body string) string {
+	body = strings.Replace(body, "<li>", "* ", -1)
+	body = strings.Replace(body, "<br />", "\n", -1)
+	body = htmltagrx.ReplaceAllString(body, "")
+	body = blanklinesrx.ReplaceAllString(body, "\n\n")
+	return strings.TrimSpace(dataset.DecodeEntities(body)) + "\n"
+}
+
+func getCommand(args []string) {
+	fs := flag.NewFlagSet("get", flag.ExitOnError)
+	format := fs.String("format", "raw", "raw for the wikitext, html for the page as /wiki/ serves it, text for just its text, or json for the wikitext and its revision")
+	words := parseCommandFlags(fs, args)
+	if len(words) == 0 {
+		panic(GracefulError("Usage: bzwikipedia get <title> [-format raw|html|text|json]"))
+	}
+
+	ds := commandDataset()
+	defer ds.Close()
+
+	pagetitle := ds.Normalize(strings.Join(words, " "))
+	td, ok := ds.FindTitle(pagetitle)
+	if !ok {
+		title, _ := ds.LookupFold(pagetitle, searchOptions())
+		if title == "" {
+			panic(GracefulError(fmt.Sprintf("No such page: '%v'", dataset.DecodeEntities(pagetitle))))
+		}
+		td, _ = ds.FindTitle(title)
+	}
+	wp := ds.ReadPage(td, true)
+
+	switch *format {
+	case "raw":
+		os.Stdout.Write([]byte(wp.Text))
+	case "html":
+		page, _ := renderTemplate(conf["wiki_template"], wikiPage(wp))
+		os.Stdout.Write([]byte(page))
+	case "text":
+		body, _ := wiki2html.Wiki2HTML(wp.Text)
+		os.Stdout.Write([]byte(htmlToText(body)))
+	case "json":
+		data, err := json.Marshal(pageJSON(wp))
+		if err != nil {
+			panic(GracefulError(fmt.Sprintf("Unable to encode '%v': %v", wp.Title, err)))
+		}
+		os.Stdout.Write(data)
+		fmt.Println()
+	default:
+		panic(GracefulError(fmt.Sprintf("Unknown format '%v': Use raw, html, text or json", *format)))
+	}
+}
+
+func searchCommand(args []string) {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	namespaces := fs.String("ns", "", "only search these namespaces, as in 0,14")
+	text := fs.Bool("text", false, "search what pages say rather than their titles")
+	fuzzy := fs.Bool("fuzzy", false, "allow for the phrase being misspelt")
+	words := parseCommandFlags(fs, args)
+	if len(words) == 0 {
+		panic(GracefulError("Usage: bzwikipedia search <phrase> [-ns 0,14] [-text|-fuzzy]"))
+	}
+
+	ds := commandDataset()
+	defer ds.Close()
+
+	opts := searchOptions()
+	opts.Namespaces = parseNamespaceList(*namespaces)
+	var titles []string
+	if *text {
+		if ds.Text == nil {
+			panic(GracefulError("There is no text index. Set text_index: yes to build one."))
+		}
+		var complete bool
+		titles, complete = ds.SearchText(strings.Join(words, " "), opts)
+		if !complete {
+			fmt.Fprintln(os.Stderr, "Too many pages to check for the phrase, so these are only some of them.")
+		}
+	} else if *fuzzy {
+		if ds.Trigrams == nil {
+			panic(GracefulError("There is no trigram index. Set trigram_index: yes to build one."))
+		}
+		titles = hitTitles(ds.SearchFuzzy(strings.Join(words, " "), opts))
+	} else {
+		titles = ds.Search(strings.Join(words, " "), opts)
+	}
+	for _, title := range titles {
+		fmt.Println(dataset.DecodeEntities(title))
+	}
+}
+
+func titlesCommand(args []string) {
+	fs := flag.NewFlagSet("titles", flag.ExitOnError)
+	prefix := fs.String("prefix", "", "only list the titles starting with this")
+	parseCommandFlags(fs, args)
+
+	ds := commandDataset()
+	defer ds.Close()
+
+	start := ""
+	if *prefix != "" {
+		start = ds.Normalize(*prefix)
+	}
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	for tc := ds.Seek([]byte(start)); tc.Valid(); tc.Next() {
+		title := tc.Title()
+		if !bytes.HasPrefix(title, []byte(start)) {
+			break
+		}
+		fmt.Fprintln(out, dataset.DecodeEntities(string(title)))
+	}
+}
+
+func statsCommand(args []string) {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	parseCommandFlags(fs, args)
+
+	ds := commandDataset()
+	defer ds.Close()
+
+	d := ds.Dat
+	fmt.Printf("dbname: %v\n", ds.Dbname)
+	fmt.Printf("dbpath: %v\n", d["dbpath"])
+	fmt.Printf("version: %v\n", d["version"])
+	fmt.Printf("records: %d\n", ds.Count)
+	fmt.Printf("chunks: %d\n", ds.Chunks.Chunks())
+	fmt.Printf("storage: %v\n", dataset.StorageType(d))
+	fmt.Printf("format: %v\n", ds.Format)
+	fmt.Printf("titlecache: %d bytes\n", ds.Size)
+	fmt.Printf("redirects: %v\n", d["redirects"])
+	fmt.Printf("namespaces: %d\n", len(ds.Namespaces))
+	if d["gendir"] != "" {
+		fmt.Printf("generation: %v\n", d["gendir"])
+	}
+}
+
 type GracefulError string
-type RestartSignal string
+
+// Whether to say what we're doing while starting up.
+var verbose = true
 
 var conffile = flag.String("conf", "bzwikipedia.conf", "specify an alternate config file to use")
 var basedir = flag.String("basedir", "", "alternate dir to use as base to find conffile and other configured files from. defaults to where the executable lives")
@@ -1204,9 +3806,6 @@ func main() {
 	// 
 	// Any error of type GracefulError is handled with an exit(1)
 	// rather than by handing the user a backtrace.
-	//
-	// An error of type RestartSignal is technically not an error
-	// but is the cleanest way to ensure no defers are skipped.
 	defer func() {
 		problem := recover()
 		if problem == nil {
@@ -1216,14 +3815,6 @@ func main() {
 		case GracefulError:
 			fmt.Println(problem)
 			os.Exit(1)
-		case RestartSignal:
-			fmt.Println(problem)
-			// Probably requires closing any fds still open
-			// Will investigate later
-			os.Exec(os.Args[0], os.Args, os.Envs)
-			// If we're still here something went wrong.
-			fmt.Println("Couldn't restart. You'll have to restart manually.")
-			os.Exit(0)
 		default:
 			panic(problem)
 		}
@@ -1231,31 +3822,73 @@ func main() {
 
 	flag.Parse()
 
+	// Commands keep quiet about what they're doing, so their output can be
+	// piped somewhere.
+	command, isCommand := commands[flag.Arg(0)]
+	if flag.NArg() > 0 && !isCommand {
+		panic(GracefulError(fmt.Sprintf("Unknown command '%v': Use get, search, titles or stats", flag.Arg(0))))
+	}
+	verbose = !isCommand
+
 	if *basedir == "" {
 		*basedir = dirname(os.Args[0])
 	}
 
-	fmt.Println("Switching dir to", *basedir)
+	if verbose {
+		fmt.Println("Switching dir to", *basedir)
+	}
 	os.Chdir(*basedir)
 
 	parseConfig(*conffile)
 	parseNameSpaces(conf["namespace_file"])
 
-	// Check for any new databases, including initial startup, and
-	// perform pre-processing.
-	hadFiles := performUpdates()
+	prepSearchConfig()
+
+	if isCommand {
+		command(flag.Args()[1:])
+		return
+	}
+
+	prepRecents()
+
+	fmt.Printf("Forcing Go to use %d max threads.\n", searchRoutines)
+	runtime.GOMAXPROCS(searchRoutines)
+
+	// For /random.
+	rand.Seed(time.Nanoseconds())
+
+	// Load in the title cache we already have, if any.
+	var olddat map[string]string
+	ds, err := openDataset(conf["dat_file"])
+	if err != nil {
+		fmt.Printf("Unable to load '%v': %v\n", conf["dat_file"], err)
+	} else {
+		olddat = ds.Dat
+	}
+
+	// Check for any new databases, including initial startup.
+	in := pendingIngest(olddat)
 
-	// Load in the title cache
-	if !loadTitleFile() {
-		if hadFiles {
+	if ds != nil {
+		publishDataset(ds, conf["dat_file"])
+		// Keep serving the old one while the new one is built.
+		if in != nil {
+			startIngest(in)
+		}
+	} else if in != nil {
+		// Nothing to serve in the meantime, so build it first.
+		in.Run()
+		if !in.Activate() {
 			fmt.Println("Unable to read Title cache file: Invalid format?")
-		} else {
-			fmt.Println("\n\nNo wiki files found and unable to read title cache.\n\nIf you never downloaded a wikipedia dump, you will have to do that now.\nIf you have, something went wrong or the cache format changed.\nYou will probably have to supply a new dump or put back your old one.")
+			return
 		}
+	} else {
+		fmt.Println("\n\nNo wiki files found and unable to read title cache.\n\nIf you never downloaded a wikipedia dump, you will have to do that now.\nIf you have, something went wrong or the cache format changed.\nYou will probably have to supply a new dump or put back your old one.")
 		return
 	}
-	prepSearchRoutines()
-	prepRecents()
+
+	// Pick up new dumps as they show up.
+	go watchDropDir()
 
 	fmt.Println("Loaded! Starting webserver . . .")
 
@@ -1263,18 +3896,25 @@ func main() {
 	http.HandleFunc("/wiki/", pageHandle)
 	// /search/ look for given text
 	http.HandleFunc("/search/", searchHandle)
+	// /random, a random page, and /allpages, every title in order
+	http.HandleFunc("/random", randomHandle)
+	http.HandleFunc("/allpages", allPagesHandle)
 	// /recent, a list of recent searches
 	http.HandleFunc("/recent", recentHandle)
+	// /admin/generations, list and switch between generations
+	http.HandleFunc("/admin/generations", generationsHandle)
+	// /status, what's being served and built
+	http.HandleFunc("/status", statusHandle)
+	http.HandleFunc("/status.json", statusJSONHandle)
+	// /api/suggest, titles starting with a prefix, as JSON
+	http.HandleFunc("/api/suggest", suggestHandle)
 
 	// Everything else is served from the web dir.
 	http.Handle("/", http.FileServer(http.Dir(conf["web_dir"])))
 
-	fmt.Printf("Forcing Go to use %d max threads.\n", searchRoutines)
-	runtime.GOMAXPROCS(searchRoutines)
-
 	fmt.Println("Starting Web server on port", conf["listen"])
 
-	err := http.ListenAndServe(conf["listen"], nil)
+	err = http.ListenAndServe(conf["listen"], nil)
 	if err != nil {
 		fmt.Println("Fatal error:", err.String())
 	}
@@ -0,0 +1,768 @@
+// textindex.go
+//
+// Uses: A full-text index of the pages of a dump, so that pages can be found
+// by what they say and not only by their titles.
+//
+// While the chunks are scanned, a Builder is told about each page and the
+// words in it, and writes out an inverted index: For every word, the
+// numbers of the pages it is in. An Index reads it back.
+//
+// Titles can be indexed the same way, each being a page of its own with
+// its Trigrams as words, to find them by bits of what they're called.
+//
+////// Text index file format:
+// title\n                                  (one per page, by page number)
+// word\0 count length postings             (one per word, sorted by word)
+// ndocs nwords\n                           (the trailer: how many of each,
+// offset\n ...                              then where every BlockSize-th
+// offset\n ...                              page and word start)
+// trailer offset, as 19 digits\n
+//
+// count and length are how many pages the word is in and how many bytes
+// the postings take. Those are the page numbers, each as how much it is
+// past the one before it (the first past 0), in varints. Pages are
+// numbered in the order they are in the dump, from 0.
+
+package textindex
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"fmt"
+	"io"
+	"loadfile"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"textnorm"
+)
+
+// Every how many pages and words the trailer says where they start.
+const BlockSize = 64
+
+func putUvarint(buff *bytes.Buffer, x uint64) {
+	for x >= 0x80 {
+		buff.WriteByte(byte(x) | 0x80)
+		x >>= 7
+	}
+	buff.WriteByte(byte(x))
+}
+
+// The varint at the start of b, and how many bytes it took; 0 if it's cut
+// short.
+func uvarint(b []byte) (uint64, int) {
+	var x uint64
+	var shift uint
+	for i, c := range b {
+		if c < 0x80 {
+			return x | uint64(c)<<shift, i + 1
+		}
+		x |= uint64(c&0x7f) << shift
+		shift += 7
+	}
+	return 0, 0
+}
+
+func readUvarint(r io.ByteReader) (uint64, os.Error) {
+	var x uint64
+	var shift uint
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if c < 0x80 {
+			return x | uint64(c)<<shift, nil
+		}
+		x |= uint64(c&0x7f) << shift
+		shift += 7
+	}
+	return 0, nil
+}
+
+func encodePostings(docs []int) []byte {
+	buff := bytes.NewBuffer(make([]byte, 0, len(docs)*2))
+	last := 0
+	for _, doc := range docs {
+		putUvarint(buff, uint64(doc-last))
+		last = doc
+	}
+	return buff.Bytes()
+}
+
+func decodePostings(b []byte, count int) []int {
+	docs := make([]int, 0, count)
+	last := 0
+	for len(b) > 0 {
+		delta, n := uvarint(b)
+		if n == 0 {
+			break
+		}
+		last += int(delta)
+		docs = append(docs, last)
+		b = b[n:]
+	}
+	return docs
+}
+
+func writeWord(w io.Writer, word string, docs []int) {
+	postings := encodePostings(docs)
+	head := bytes.NewBufferString(word)
+	head.WriteByte(0)
+	putUvarint(head, uint64(len(docs)))
+	putUvarint(head, uint64(len(postings)))
+	w.Write(head.Bytes())
+	w.Write(postings)
+}
+
+//
+// Builds a text index a page at a time. Pages are numbered as they're
+// started. What a page says can come in bits, and the words seen so far
+// are written out to runs in dir whenever they take up more than budget,
+// to be merged at the end.
+//
+type Builder struct {
+	dir, name string
+	budget    int64
+	used      int64
+	words     map[string][]int
+	runs      []string
+	// The titles, by page number, as they come.
+	docs     *os.File
+	docsOut  *bufio.Writer
+	docCount int
+	// The page being indexed, -1 if none, and the words already noted for
+	// it.
+	cur  int
+	seen map[string]bool
+}
+
+// A rough guess at what each word costs us beyond its letters.
+const wordOverhead = 48
+
+//
+// A Builder keeping its temporary files in dir, named starting with name.
+// If cp is what Flush returned before a build was interrupted, it carries
+// on from there.
+//
+func NewBuilder(dir, name string, budget int64, cp map[string]string) (*Builder, os.Error) {
+	b := &Builder{
+		dir:    dir,
+		name:   name,
+		budget: budget,
+		words:  map[string][]int{},
+		cur:    -1,
+		seen:   map[string]bool{},
+	}
+	if cp != nil && cp["textruns"] != "" {
+		if err := b.resume(cp); err != nil {
+			return nil, err
+		}
+		return b, nil
+	}
+	var err os.Error
+	b.docs, err = os.OpenFile(b.docsFileName(), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return nil, err
+	}
+	b.docsOut = bufio.NewWriter(b.docs)
+	return b, nil
+}
+
+func (b *Builder) docsFileName() string {
+	return filepath.Join(b.dir, b.name+"docs.tmp")
+}
+
+func (b *Builder) runFileName(n int) string {
+	return filepath.Join(b.dir, fmt.Sprintf("%vrun%04d.tmp", b.name, n))
+}
+
+// A new page starts, titled title as in the dump.
+func (b *Builder) StartPage(title string) {
+	b.cur = b.docCount
+	b.docCount++
+	b.docsOut.WriteString(title)
+	b.docsOut.WriteByte('\n')
+	b.seen = map[string]bool{}
+}
+
+// The page turns out not to be worth indexing: It's a redirect.
+func (b *Builder) SkipPage() {
+	b.cur = -1
+}
+
+// Some of what the current page says.
+func (b *Builder) AddWords(words []string) {
+	if b.cur < 0 {
+		return
+	}
+	for _, word := range words {
+		if b.seen[word] {
+			continue
+		}
+		b.seen[word] = true
+		docs, ok := b.words[word]
+		if !ok {
+			b.used += int64(len(word)) + wordOverhead
+		}
+		b.words[word] = append(docs, b.cur)
+		b.used += 8
+	}
+}
+
+// Write out a run if the words are taking up too much room.
+func (b *Builder) MaybeFlush() (bool, os.Error) {
+	if b.used < b.budget {
+		return false, nil
+	}
+	_, err := b.Flush()
+	return true, err
+}
+
+//
+// Write the words seen so far out to a run, and the titles to disk, so
+// that an interrupted build can carry on from here. Returns what
+// NewBuilder needs to know to do that.
+//
+func (b *Builder) Flush() (map[string]string, os.Error) {
+	if err := b.docsOut.Flush(); err != nil {
+		return nil, err
+	}
+	size, _ := b.docs.Seek(0, 1)
+
+	if len(b.words) > 0 {
+		words := make([]string, 0, len(b.words))
+		for word := range b.words {
+			words = append(words, word)
+		}
+		sort.Strings(words)
+
+		fn := b.runFileName(len(b.runs))
+		fout, err := os.OpenFile(fn, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+		if err != nil {
+			return nil, err
+		}
+		bout := bufio.NewWriter(fout)
+		for _, word := range words {
+			writeWord(bout, word, b.words[word])
+		}
+		err = bout.Flush()
+		fout.Close()
+		if err != nil {
+			return nil, err
+		}
+		fmt.Printf("Wrote %d words to %v\n", len(words), fn)
+		b.runs = append(b.runs, fn)
+		b.words = map[string][]int{}
+		b.used = 0
+	}
+
+	return map[string]string{
+		"textruns": strconv.Itoa(len(b.runs)),
+		"textdocs": strconv.Itoa(b.docCount),
+		"textsize": strconv.Itoa64(size),
+		"textcur":  strconv.Itoa(b.cur),
+	}, nil
+}
+
+//
+// Pick up from what Flush returned. The scan must carry on from where it
+// was when Flush was called, or pages get numbered twice. The words already
+// noted for the page it was in the middle of aren't kept, so any that get
+// noted again are taken care of when merging.
+//
+func (b *Builder) resume(cp map[string]string) os.Error {
+	runs, err := strconv.Atoi(cp["textruns"])
+	if err != nil {
+		return err
+	}
+	size, err := strconv.Atoi64(cp["textsize"])
+	if err != nil {
+		return err
+	}
+	b.docCount, _ = strconv.Atoi(cp["textdocs"])
+	b.cur, _ = strconv.Atoi(cp["textcur"])
+
+	for i := 0; i < runs; i++ {
+		b.runs = append(b.runs, b.runFileName(i))
+	}
+	b.docs, err = os.OpenFile(b.docsFileName(), os.O_WRONLY, 0666)
+	if err != nil {
+		return err
+	}
+	if err = b.docs.Truncate(size); err != nil {
+		return err
+	}
+	if _, err = b.docs.Seek(size, 0); err != nil {
+		return err
+	}
+	b.docsOut = bufio.NewWriter(b.docs)
+	return nil
+}
+
+// One run being merged, and the word at its head.
+type runReader struct {
+	bin  *bufio.Reader
+	fin  *os.File
+	word string
+	docs []int
+}
+
+func (rr *runReader) Next() bool {
+	word, err := rr.bin.ReadString(0)
+	if err != nil {
+		return false
+	}
+	count, err := readUvarint(rr.bin)
+	if err != nil {
+		return false
+	}
+	length, err := readUvarint(rr.bin)
+	if err != nil {
+		return false
+	}
+	postings := make([]byte, length)
+	if _, err = io.ReadFull(rr.bin, postings); err != nil {
+		return false
+	}
+	rr.word = word[:len(word)-1]
+	rr.docs = decodePostings(postings, int(count))
+	return true
+}
+
+// The runs, by the word at their head, and the earliest run first.
+type runHeap []*runReader
+
+func (rh runHeap) Len() int {
+	return len(rh)
+}
+func (rh runHeap) Less(a, b int) bool {
+	if rh[a].word != rh[b].word {
+		return rh[a].word < rh[b].word
+	}
+	return rh[a].docs[0] < rh[b].docs[0]
+}
+func (rh runHeap) Swap(a, b int) {
+	rh[a], rh[b] = rh[b], rh[a]
+}
+func (rh *runHeap) Push(x interface{}) {
+	*rh = append(*rh, x.(*runReader))
+}
+func (rh *runHeap) Pop() interface{} {
+	old := *rh
+	rr := old[len(old)-1]
+	*rh = old[:len(old)-1]
+	return rr
+}
+
+//
+// Write the index out to fn, merging the runs, and clear away the
+// temporary files. Returns how many pages it has.
+//
+func (b *Builder) WriteTo(fn string) (int, os.Error) {
+	_, err := b.Flush()
+	b.docs.Close()
+	defer func() {
+		os.Remove(b.docsFileName())
+		for _, run := range b.runs {
+			os.Remove(run)
+		}
+	}()
+
+	if err != nil {
+		return 0, err
+	}
+	fout, err := os.OpenFile(fn, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return 0, err
+	}
+	defer fout.Close()
+	bout := bufio.NewWriter(fout)
+	var offset int64
+	var docBlocks, wordBlocks []int64
+
+	// The titles, noting where every BlockSize-th one starts.
+	din, err := os.Open(b.docsFileName())
+	if err != nil {
+		return 0, err
+	}
+	dbin := bufio.NewReader(din)
+	for n := 0; n < b.docCount; n++ {
+		title, err := dbin.ReadString('\n')
+		if err != nil {
+			din.Close()
+			return 0, fmt.Errorf("%v: Only %d of %d titles", b.docsFileName(), n, b.docCount)
+		}
+		if n%BlockSize == 0 {
+			docBlocks = append(docBlocks, offset)
+		}
+		bout.WriteString(title)
+		offset += int64(len(title))
+	}
+	din.Close()
+
+	// The words, merging the runs.
+	rh := &runHeap{}
+	for _, run := range b.runs {
+		fin, err := os.Open(run)
+		if err != nil {
+			return 0, err
+		}
+		defer fin.Close()
+		rr := &runReader{fin: fin, bin: bufio.NewReader(fin)}
+		if rr.Next() {
+			heap.Push(rh, rr)
+		}
+	}
+
+	counter := &countingWriter{w: bout}
+	wordCount := 0
+	for rh.Len() > 0 {
+		rr := heap.Pop(rh).(*runReader)
+		word := rr.word
+		docs := rr.docs
+		if rr.Next() {
+			heap.Push(rh, rr)
+		}
+		// The same word from later runs. The page a run ended in can be
+		// in the next one too.
+		for rh.Len() > 0 && (*rh)[0].word == word {
+			rr = heap.Pop(rh).(*runReader)
+			docs = append(docs, rr.docs...)
+			if rr.Next() {
+				heap.Push(rh, rr)
+			}
+		}
+		docs = sortedDocs(docs)
+
+		if wordCount%BlockSize == 0 {
+			wordBlocks = append(wordBlocks, offset+counter.n)
+		}
+		writeWord(counter, word, docs)
+		wordCount++
+	}
+	offset += counter.n
+
+	fmt.Fprintf(bout, "%d %d\n", b.docCount, wordCount)
+	for _, block := range docBlocks {
+		fmt.Fprintf(bout, "%d\n", block)
+	}
+	for _, block := range wordBlocks {
+		fmt.Fprintf(bout, "%d\n", block)
+	}
+	fmt.Fprintf(bout, "%019d\n", offset)
+
+	if err = bout.Flush(); err != nil {
+		return 0, err
+	}
+	return b.docCount, nil
+}
+
+// docs in order, each only once.
+func sortedDocs(docs []int) []int {
+	if !sort.IntsAreSorted(docs) {
+		sort.Ints(docs)
+	}
+	out := docs[:1]
+	for _, doc := range docs[1:] {
+		if doc != out[len(out)-1] {
+			out = append(out, doc)
+		}
+	}
+	return out
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, os.Error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+//
+// A text index, as written by a Builder.
+//
+type Index struct {
+	blob                  []byte
+	docCount, wordCount   int
+	docBlocks, wordBlocks []int64
+	// Where the words end and the trailer starts.
+	end int64
+}
+
+func Open(fn string, mmap bool) (*Index, os.Error) {
+	success, size, blob := loadfile.ReadFile(fn, mmap)
+	if !success {
+		return nil, fmt.Errorf("Unable to read %v", fn)
+	}
+	ix := &Index{blob: blob}
+	if err := ix.readTrailer(size); err != nil {
+		ix.Close()
+		return nil, fmt.Errorf("%v: %v", fn, err)
+	}
+	return ix, nil
+}
+
+func (ix *Index) readTrailer(size int64) os.Error {
+	if size < 20 {
+		return fmt.Errorf("Too short to be a text index")
+	}
+	var err os.Error
+	ix.end, err = strconv.Atoi64(string(ix.blob[size-20 : size-1]))
+	if err != nil || ix.end < 0 || ix.end > size-20 {
+		return fmt.Errorf("No trailer")
+	}
+
+	lines := strings.Split(string(ix.blob[ix.end:size-20]), "\n")
+	counts := strings.Fields(lines[0])
+	if len(counts) != 2 {
+		return fmt.Errorf("Broken trailer")
+	}
+	ix.docCount, err = strconv.Atoi(counts[0])
+	if err == nil {
+		ix.wordCount, err = strconv.Atoi(counts[1])
+	}
+	if err != nil {
+		return fmt.Errorf("Broken trailer")
+	}
+
+	docBlocks := (ix.docCount + BlockSize - 1) / BlockSize
+	wordBlocks := (ix.wordCount + BlockSize - 1) / BlockSize
+	if len(lines) < 1+docBlocks+wordBlocks {
+		return fmt.Errorf("Broken trailer")
+	}
+	for i, line := range lines[1 : 1+docBlocks+wordBlocks] {
+		offset, err := strconv.Atoi64(line)
+		if err != nil || offset > ix.end {
+			return fmt.Errorf("Broken trailer")
+		}
+		if i < docBlocks {
+			ix.docBlocks = append(ix.docBlocks, offset)
+		} else {
+			ix.wordBlocks = append(ix.wordBlocks, offset)
+		}
+	}
+	return nil
+}
+
+func (ix *Index) Close() {
+	if ix.blob != nil {
+		loadfile.Release(ix.blob)
+		ix.blob = nil
+	}
+}
+
+// The title of page doc, as in the dump.
+func (ix *Index) Title(doc int) string {
+	if doc < 0 || doc >= ix.docCount {
+		return ""
+	}
+	pos := ix.docBlocks[doc/BlockSize]
+	for n := doc % BlockSize; n > 0; n-- {
+		pos += int64(bytes.IndexByte(ix.blob[pos:], '\n')) + 1
+	}
+	end := pos + int64(bytes.IndexByte(ix.blob[pos:], '\n'))
+	return string(ix.blob[pos:end])
+}
+
+// The word at pos, its page count, and where its postings are.
+func (ix *Index) wordAt(pos int64) (word []byte, count int, start, end int64) {
+	nul := int64(bytes.IndexByte(ix.blob[pos:ix.end], 0))
+	word = ix.blob[pos : pos+nul]
+	pos += nul + 1
+	c, n := uvarint(ix.blob[pos:ix.end])
+	pos += int64(n)
+	length, n := uvarint(ix.blob[pos:ix.end])
+	pos += int64(n)
+	return word, int(c), pos, pos + int64(length)
+}
+
+// A word's postings, still encoded: How many pages, and where they are.
+type postingList struct {
+	count      int
+	start, end int64
+}
+
+// Where word's postings are, if it's in the index at all.
+func (ix *Index) lookup(word string) (postingList, bool) {
+	if len(ix.wordBlocks) == 0 {
+		return postingList{}, false
+	}
+	needle := []byte(word)
+
+	// The last block starting at or before word.
+	b := sort.Search(len(ix.wordBlocks), func(i int) bool {
+		w, _, _, _ := ix.wordAt(ix.wordBlocks[i])
+		return bytes.Compare(w, needle) > 0
+	}) - 1
+	if b < 0 {
+		return postingList{}, false
+	}
+
+	pos := ix.wordBlocks[b]
+	for n := 0; n < BlockSize && pos < ix.end; n++ {
+		w, count, start, end := ix.wordAt(pos)
+		switch bytes.Compare(w, needle) {
+		case 0:
+			return postingList{count, start, end}, true
+		case 1:
+			return postingList{}, false
+		}
+		pos = end
+	}
+	return postingList{}, false
+}
+
+func (ix *Index) decode(p postingList) []int {
+	return decodePostings(ix.blob[p.start:p.end], p.count)
+}
+
+// The pages word is in, in order.
+func (ix *Index) Postings(word string) []int {
+	p, ok := ix.lookup(word)
+	if !ok {
+		return []int{}
+	}
+	return ix.decode(p)
+}
+
+type byCount []postingList
+
+func (bc byCount) Len() int {
+	return len(bc)
+}
+func (bc byCount) Less(a, b int) bool {
+	return bc[a].count < bc[b].count
+}
+func (bc byCount) Swap(a, b int) {
+	bc[a], bc[b] = bc[b], bc[a]
+}
+
+//
+// The pages that have every one of words, in order.
+//
+func (ix *Index) Match(words []string) []int {
+	docs, _ := ix.MatchAtMost(words, 0)
+	return docs
+}
+
+//
+// Match, unless more than limit pages (if limit > 0) have every one of
+// words, in which case it gives up and returns false. The rarest words are
+// gone through first, and the common ones are left encoded until then, so
+// that there's as little as possible to go through.
+//
+func (ix *Index) MatchAtMost(words []string, limit int) ([]int, bool) {
+	if len(words) == 0 {
+		return []int{}, true
+	}
+	lists := make([]postingList, len(words))
+	for i, word := range words {
+		p, ok := ix.lookup(word)
+		if !ok {
+			return []int{}, true
+		}
+		lists[i] = p
+	}
+	sort.Sort(byCount(lists))
+
+	result := ix.decode(lists[0])
+	for _, p := range lists[1:] {
+		if len(result) == 0 {
+			break
+		}
+		list := ix.decode(p)
+		both := []int{}
+		j := 0
+		for _, doc := range result {
+			for j < len(list) && list[j] < doc {
+				j++
+			}
+			if j < len(list) && list[j] == doc {
+				both = append(both, doc)
+			}
+		}
+		result = both
+	}
+	if limit > 0 && len(result) > limit {
+		return nil, false
+	}
+	return result, true
+}
+
+//
+// A search: Words that must all be there, and phrases, whose words must
+// also be there in a row. "AND" between them is allowed, but it's what
+// happens anyway.
+//
+type Query struct {
+	Words   []string
+	Phrases [][]string
+}
+
+func ParseQuery(q string) Query {
+	query := Query{Words: []string{}}
+	parts := strings.Split(q, "\"")
+	for i, part := range parts {
+		// Every other part is in quotes.
+		if i%2 == 1 {
+			words := textnorm.Words(part)
+			query.Words = append(query.Words, words...)
+			if len(words) > 1 {
+				query.Phrases = append(query.Phrases, words)
+			}
+			continue
+		}
+		for _, field := range strings.Fields(part) {
+			if field != "AND" {
+				query.Words = append(query.Words, textnorm.Words(field)...)
+			}
+		}
+	}
+	return query
+}
+
+// Whether words has every one of phrases in it.
+func HasPhrases(words []string, phrases [][]string) bool {
+	for _, phrase := range phrases {
+		found := false
+		for i := 0; i+len(phrase) <= len(words) && !found; i++ {
+			found = true
+			for j, word := range phrase {
+				if words[i+j] != word {
+					found = false
+					break
+				}
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+//
+// The trigrams of key, each once: Every run of three characters in it.
+// Keys shorter than that have none.
+//
+func Trigrams(key string) []string {
+	runes := []int(key)
+	seen := map[string]bool{}
+	trigrams := []string{}
+	for i := 0; i+3 <= len(runes); i++ {
+		trigram := string(runes[i : i+3])
+		if !seen[trigram] {
+			seen[trigram] = true
+			trigrams = append(trigrams, trigram)
+		}
+	}
+	return trigrams
+}
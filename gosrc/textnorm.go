@@ -0,0 +1,744 @@
+// textnorm.go
+//
+// Uses: Bringing text that means the same into the same form, so that titles
+// typed in or linked to in different ways can be found.
+//
+// NFC(s) composes letters followed by combining marks into the single
+// characters Unicode has for them, as MediaWiki does with every title.
+// Text from a Mac or from a browser that sends decomposed characters ends up
+// the same as what is in the dump.
+//
+// Runs of marks are put in canonical order first, by their combining class,
+// so that the same marks typed in a different order come out the same.
+//
+// Fold(s) decomposes characters (NFKD), leaving out the accents and other
+// marks that leaves, and lowercases what's left, so that "Gödel" and
+// "Godel" are the same to search.
+//
+// Words(s) splits text into folded words, the same way for indexing it as
+// for searching it. Key(s) runs them together, which is what title search
+// goes by.
+
+package textnorm
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+	"utf8"
+)
+
+// Hangul syllables are composed by arithmetic rather than from the table.
+const (
+	hangulBase   = 0xAC00
+	hangulLBase  = 0x1100
+	hangulVBase  = 0x1161
+	hangulTBase  = 0x11A7
+	hangulLCount = 19
+	hangulVCount = 21
+	hangulTCount = 28
+	hangulNCount = hangulVCount * hangulTCount
+	hangulSCount = hangulLCount * hangulNCount
+)
+
+var compositions map[int64]int
+
+// The other way around: The two characters each one decomposes into.
+var decompositions map[int][2]int
+
+func init() {
+	compositions = make(map[int64]int, len(compositionTable)/3)
+	decompositions = make(map[int][2]int, len(compositionTable)/3)
+	for i := 0; i+2 < len(compositionTable); i += 3 {
+		compositions[pairKey(compositionTable[i], compositionTable[i+1])] = compositionTable[i+2]
+		decompositions[compositionTable[i+2]] = [2]int{compositionTable[i], compositionTable[i+1]}
+	}
+}
+
+func pairKey(a, b int) int64 {
+	return int64(a)<<21 | int64(b)
+}
+
+// The single character for a followed by b, if there is one.
+func compose(a, b int) (int, bool) {
+	if a >= hangulLBase && a < hangulLBase+hangulLCount &&
+		b >= hangulVBase && b < hangulVBase+hangulVCount {
+		return hangulBase + ((a-hangulLBase)*hangulVCount+b-hangulVBase)*hangulTCount, true
+	}
+	if a >= hangulBase && a < hangulBase+hangulSCount && (a-hangulBase)%hangulTCount == 0 &&
+		b > hangulTBase && b < hangulTBase+hangulTCount {
+		return a + b - hangulTBase, true
+	}
+	c, ok := compositions[pairKey(a, b)]
+	return c, ok
+}
+
+// The canonical combining class of r: 0 for letters and the like, and the
+// order it goes in among other marks otherwise.
+func combiningClass(r int) int {
+	lo, hi := 0, len(combiningTable)/3
+	for lo < hi {
+		mid := (lo + hi) / 2
+		switch {
+		case r < combiningTable[mid*3]:
+			hi = mid
+		case r > combiningTable[mid*3+1]:
+			lo = mid + 1
+		default:
+			return combiningTable[mid*3+2]
+		}
+	}
+	return 0
+}
+
+// Sort each run of marks by combining class, keeping the order of those
+// of the same class.
+func reorderMarks(runes []int) {
+	for i := 1; i < len(runes); i++ {
+		class := combiningClass(runes[i])
+		if class == 0 {
+			continue
+		}
+		for j := i; j > 0; j-- {
+			prev := combiningClass(runes[j-1])
+			if prev <= class {
+				break
+			}
+			runes[j-1], runes[j] = runes[j], runes[j-1]
+		}
+	}
+}
+
+//
+// Return s in Unicode Normalization Form C, or close enough to it for
+// titles.
+//
+func NFC(s string) string {
+	// Nothing to compose in plain ASCII, which is most of what we see.
+	ascii := true
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return s
+	}
+
+	runes := []int(s)
+	reorderMarks(runes)
+
+	// A mark composes with the letter before it unless a mark of the same
+	// class or a letter comes in between.
+	out := make([]int, 0, len(runes))
+	starter, lastClass := -1, 0
+	for _, r := range runes {
+		class := combiningClass(r)
+		if starter >= 0 && (starter == len(out)-1 || lastClass != 0 && lastClass < class) {
+			if c, ok := compose(out[starter], r); ok {
+				out[starter] = c
+				continue
+			}
+		}
+		if class == 0 {
+			starter = len(out)
+		}
+		lastClass = class
+		out = append(out, r)
+	}
+
+	buff := bytes.NewBuffer(make([]byte, 0, len(s)))
+	for _, r := range out {
+		buff.WriteRune(r)
+	}
+	return buff.String()
+}
+
+// Characters that don't decompose into what people type for them when they
+// can't type them, but ought to fold into it all the same.
+var specialFolds = map[int]string{
+	0x00DF: "ss", // sharp s
+	0x1E9E: "ss", // capital sharp s
+	0x0131: "i",  // dotless i
+	0x00C6: "ae", 0x00E6: "ae",
+	0x0152: "oe", 0x0153: "oe",
+	0x00D8: "o", 0x00F8: "o",
+	0x0141: "l", 0x0142: "l",
+	0x0110: "d", 0x0111: "d",
+	0x00D0: "d", 0x00F0: "d", // eth
+	0x00DE: "th", 0x00FE: "th", // thorn
+	0x0126: "h", 0x0127: "h",
+	0x0166: "t", 0x0167: "t",
+}
+
+//
+// s folded for searching: Decomposed as far as Unicode goes, compatibility
+// decompositions and all, with the marks that leaves (accents and the
+// like) left out, and lowercased. Ligatures come apart, full width letters
+// become the usual ones, and specialFolds takes care of ß and the like.
+// "Gödel", "GÖDEL" and "Ｇodel" all come to "godel".
+//
+func Fold(s string) string {
+	ascii := true
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return strings.ToLower(s)
+	}
+
+	buff := bytes.NewBuffer(make([]byte, 0, len(s)))
+	for _, r := range s {
+		foldRune(buff, r)
+	}
+	return buff.String()
+}
+
+func foldRune(buff *bytes.Buffer, r int) {
+	if folded, ok := specialFolds[r]; ok {
+		buff.WriteString(folded)
+		return
+	}
+	if folded, ok := compatibilityTable[r]; ok {
+		for _, c := range folded {
+			foldRune(buff, c)
+		}
+		return
+	}
+	if pair, ok := decompositions[r]; ok {
+		foldRune(buff, pair[0])
+		foldRune(buff, pair[1])
+		return
+	}
+	if unicode.Is(unicode.Mn, r) {
+		return
+	}
+	buff.WriteRune(unicode.ToLower(r))
+}
+
+// Longer words than this are cut short. They're mostly URLs and such.
+const MaxWordLen = 64
+
+//
+// The words in s, folded: Runs of letters and digits. XML entities, which
+// the text of a dump is full of, aren't words.
+//
+func Words(s string) []string {
+	s = Fold(s)
+	words := []string{}
+	start := -1
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			i += size
+			continue
+		}
+		if start >= 0 {
+			words = append(words, word(s[start:i]))
+			start = -1
+		}
+		if r == '&' {
+			if semi := strings.IndexRune(s[i:], ';'); semi > 0 && semi < 10 {
+				i += semi
+			}
+		}
+		i += size
+	}
+	if start >= 0 {
+		words = append(words, word(s[start:]))
+	}
+	return words
+}
+
+//
+// The letters and digits of s, folded.
+//
+func Key(s string) string {
+	return strings.Join(Words(s), "")
+}
+
+func word(s string) string {
+	if len(s) <= MaxWordLen {
+		return s
+	}
+	// Not in the middle of a character.
+	end := MaxWordLen
+	for end > 0 && !utf8.RuneStart(s[end]) {
+		end--
+	}
+	return s[:end]
+}
+
+// Pairs of characters that compose, and what they compose into, taken from
+// the Unicode Character Database with the composition exclusions left out.
+var compositionTable = []int{
+	0x0041, 0x0300, 0x00C0, 0x0041, 0x0301, 0x00C1, 0x0041, 0x0302, 0x00C2, 0x0041, 0x0303, 0x00C3,
+	0x0041, 0x0308, 0x00C4, 0x0041, 0x030A, 0x00C5, 0x0043, 0x0327, 0x00C7, 0x0045, 0x0300, 0x00C8,
+	0x0045, 0x0301, 0x00C9, 0x0045, 0x0302, 0x00CA, 0x0045, 0x0308, 0x00CB, 0x0049, 0x0300, 0x00CC,
+	0x0049, 0x0301, 0x00CD, 0x0049, 0x0302, 0x00CE, 0x0049, 0x0308, 0x00CF, 0x004E, 0x0303, 0x00D1,
+	0x004F, 0x0300, 0x00D2, 0x004F, 0x0301, 0x00D3, 0x004F, 0x0302, 0x00D4, 0x004F, 0x0303, 0x00D5,
+	0x004F, 0x0308, 0x00D6, 0x0055, 0x0300, 0x00D9, 0x0055, 0x0301, 0x00DA, 0x0055, 0x0302, 0x00DB,
+	0x0055, 0x0308, 0x00DC, 0x0059, 0x0301, 0x00DD, 0x0061, 0x0300, 0x00E0, 0x0061, 0x0301, 0x00E1,
+	0x0061, 0x0302, 0x00E2, 0x0061, 0x0303, 0x00E3, 0x0061, 0x0308, 0x00E4, 0x0061, 0x030A, 0x00E5,
+	0x0063, 0x0327, 0x00E7, 0x0065, 0x0300, 0x00E8, 0x0065, 0x0301, 0x00E9, 0x0065, 0x0302, 0x00EA,
+	0x0065, 0x0308, 0x00EB, 0x0069, 0x0300, 0x00EC, 0x0069, 0x0301, 0x00ED, 0x0069, 0x0302, 0x00EE,
+	0x0069, 0x0308, 0x00EF, 0x006E, 0x0303, 0x00F1, 0x006F, 0x0300, 0x00F2, 0x006F, 0x0301, 0x00F3,
+	0x006F, 0x0302, 0x00F4, 0x006F, 0x0303, 0x00F5, 0x006F, 0x0308, 0x00F6, 0x0075, 0x0300, 0x00F9,
+	0x0075, 0x0301, 0x00FA, 0x0075, 0x0302, 0x00FB, 0x0075, 0x0308, 0x00FC, 0x0079, 0x0301, 0x00FD,
+	0x0079, 0x0308, 0x00FF, 0x0041, 0x0304, 0x0100, 0x0061, 0x0304, 0x0101, 0x0041, 0x0306, 0x0102,
+	0x0061, 0x0306, 0x0103, 0x0041, 0x0328, 0x0104, 0x0061, 0x0328, 0x0105, 0x0043, 0x0301, 0x0106,
+	0x0063, 0x0301, 0x0107, 0x0043, 0x0302, 0x0108, 0x0063, 0x0302, 0x0109, 0x0043, 0x0307, 0x010A,
+	0x0063, 0x0307, 0x010B, 0x0043, 0x030C, 0x010C, 0x0063, 0x030C, 0x010D, 0x0044, 0x030C, 0x010E,
+	0x0064, 0x030C, 0x010F, 0x0045, 0x0304, 0x0112, 0x0065, 0x0304, 0x0113, 0x0045, 0x0306, 0x0114,
+	0x0065, 0x0306, 0x0115, 0x0045, 0x0307, 0x0116, 0x0065, 0x0307, 0x0117, 0x0045, 0x0328, 0x0118,
+	0x0065, 0x0328, 0x0119, 0x0045, 0x030C, 0x011A, 0x0065, 0x030C, 0x011B, 0x0047, 0x0302, 0x011C,
+	0x0067, 0x0302, 0x011D, 0x0047, 0x0306, 0x011E, 0x0067, 0x0306, 0x011F, 0x0047, 0x0307, 0x0120,
+	0x0067, 0x0307, 0x0121, 0x0047, 0x0327, 0x0122, 0x0067, 0x0327, 0x0123, 0x0048, 0x0302, 0x0124,
+	0x0068, 0x0302, 0x0125, 0x0049, 0x0303, 0x0128, 0x0069, 0x0303, 0x0129, 0x0049, 0x0304, 0x012A,
+	0x0069, 0x0304, 0x012B, 0x0049, 0x0306, 0x012C, 0x0069, 0x0306, 0x012D, 0x0049, 0x0328, 0x012E,
+	0x0069, 0x0328, 0x012F, 0x0049, 0x0307, 0x0130, 0x004A, 0x0302, 0x0134, 0x006A, 0x0302, 0x0135,
+	0x004B, 0x0327, 0x0136, 0x006B, 0x0327, 0x0137, 0x004C, 0x0301, 0x0139, 0x006C, 0x0301, 0x013A,
+	0x004C, 0x0327, 0x013B, 0x006C, 0x0327, 0x013C, 0x004C, 0x030C, 0x013D, 0x006C, 0x030C, 0x013E,
+	0x004E, 0x0301, 0x0143, 0x006E, 0x0301, 0x0144, 0x004E, 0x0327, 0x0145, 0x006E, 0x0327, 0x0146,
+	0x004E, 0x030C, 0x0147, 0x006E, 0x030C, 0x0148, 0x004F, 0x0304, 0x014C, 0x006F, 0x0304, 0x014D,
+	0x004F, 0x0306, 0x014E, 0x006F, 0x0306, 0x014F, 0x004F, 0x030B, 0x0150, 0x006F, 0x030B, 0x0151,
+	0x0052, 0x0301, 0x0154, 0x0072, 0x0301, 0x0155, 0x0052, 0x0327, 0x0156, 0x0072, 0x0327, 0x0157,
+	0x0052, 0x030C, 0x0158, 0x0072, 0x030C, 0x0159, 0x0053, 0x0301, 0x015A, 0x0073, 0x0301, 0x015B,
+	0x0053, 0x0302, 0x015C, 0x0073, 0x0302, 0x015D, 0x0053, 0x0327, 0x015E, 0x0073, 0x0327, 0x015F,
+	0x0053, 0x030C, 0x0160, 0x0073, 0x030C, 0x0161, 0x0054, 0x0327, 0x0162, 0x0074, 0x0327, 0x0163,
+	0x0054, 0x030C, 0x0164, 0x0074, 0x030C, 0x0165, 0x0055, 0x0303, 0x0168, 0x0075, 0x0303, 0x0169,
+	0x0055, 0x0304, 0x016A, 0x0075, 0x0304, 0x016B, 0x0055, 0x0306, 0x016C, 0x0075, 0x0306, 0x016D,
+	0x0055, 0x030A, 0x016E, 0x0075, 0x030A, 0x016F, 0x0055, 0x030B, 0x0170, 0x0075, 0x030B, 0x0171,
+	0x0055, 0x0328, 0x0172, 0x0075, 0x0328, 0x0173, 0x0057, 0x0302, 0x0174, 0x0077, 0x0302, 0x0175,
+	0x0059, 0x0302, 0x0176, 0x0079, 0x0302, 0x0177, 0x0059, 0x0308, 0x0178, 0x005A, 0x0301, 0x0179,
+	0x007A, 0x0301, 0x017A, 0x005A, 0x0307, 0x017B, 0x007A, 0x0307, 0x017C, 0x005A, 0x030C, 0x017D,
+	0x007A, 0x030C, 0x017E, 0x004F, 0x031B, 0x01A0, 0x006F, 0x031B, 0x01A1, 0x0055, 0x031B, 0x01AF,
+	0x0075, 0x031B, 0x01B0, 0x0041, 0x030C, 0x01CD, 0x0061, 0x030C, 0x01CE, 0x0049, 0x030C, 0x01CF,
+	0x0069, 0x030C, 0x01D0, 0x004F, 0x030C, 0x01D1, 0x006F, 0x030C, 0x01D2, 0x0055, 0x030C, 0x01D3,
+	0x0075, 0x030C, 0x01D4, 0x00DC, 0x0304, 0x01D5, 0x00FC, 0x0304, 0x01D6, 0x00DC, 0x0301, 0x01D7,
+	0x00FC, 0x0301, 0x01D8, 0x00DC, 0x030C, 0x01D9, 0x00FC, 0x030C, 0x01DA, 0x00DC, 0x0300, 0x01DB,
+	0x00FC, 0x0300, 0x01DC, 0x00C4, 0x0304, 0x01DE, 0x00E4, 0x0304, 0x01DF, 0x0226, 0x0304, 0x01E0,
+	0x0227, 0x0304, 0x01E1, 0x00C6, 0x0304, 0x01E2, 0x00E6, 0x0304, 0x01E3, 0x0047, 0x030C, 0x01E6,
+	0x0067, 0x030C, 0x01E7, 0x004B, 0x030C, 0x01E8, 0x006B, 0x030C, 0x01E9, 0x004F, 0x0328, 0x01EA,
+	0x006F, 0x0328, 0x01EB, 0x01EA, 0x0304, 0x01EC, 0x01EB, 0x0304, 0x01ED, 0x01B7, 0x030C, 0x01EE,
+	0x0292, 0x030C, 0x01EF, 0x006A, 0x030C, 0x01F0, 0x0047, 0x0301, 0x01F4, 0x0067, 0x0301, 0x01F5,
+	0x004E, 0x0300, 0x01F8, 0x006E, 0x0300, 0x01F9, 0x00C5, 0x0301, 0x01FA, 0x00E5, 0x0301, 0x01FB,
+	0x00C6, 0x0301, 0x01FC, 0x00E6, 0x0301, 0x01FD, 0x00D8, 0x0301, 0x01FE, 0x00F8, 0x0301, 0x01FF,
+	0x0041, 0x030F, 0x0200, 0x0061, 0x030F, 0x0201, 0x0041, 0x0311, 0x0202, 0x0061, 0x0311, 0x0203,
+	0x0045, 0x030F, 0x0204, 0x0065, 0x030F, 0x0205, 0x0045, 0x0311, 0x0206, 0x0065, 0x0311, 0x0207,
+	0x0049, 0x030F, 0x0208, 0x0069, 0x030F, 0x0209, 0x0049, 0x0311, 0x020A, 0x0069, 0x0311, 0x020B,
+	0x004F, 0x030F, 0x020C, 0x006F, 0x030F, 0x020D, 0x004F, 0x0311, 0x020E, 0x006F, 0x0311, 0x020F,
+	0x0052, 0x030F, 0x0210, 0x0072, 0x030F, 0x0211, 0x0052, 0x0311, 0x0212, 0x0072, 0x0311, 0x0213,
+	0x0055, 0x030F, 0x0214, 0x0075, 0x030F, 0x0215, 0x0055, 0x0311, 0x0216, 0x0075, 0x0311, 0x0217,
+	0x0053, 0x0326, 0x0218, 0x0073, 0x0326, 0x0219, 0x0054, 0x0326, 0x021A, 0x0074, 0x0326, 0x021B,
+	0x0048, 0x030C, 0x021E, 0x0068, 0x030C, 0x021F, 0x0041, 0x0307, 0x0226, 0x0061, 0x0307, 0x0227,
+	0x0045, 0x0327, 0x0228, 0x0065, 0x0327, 0x0229, 0x00D6, 0x0304, 0x022A, 0x00F6, 0x0304, 0x022B,
+	0x00D5, 0x0304, 0x022C, 0x00F5, 0x0304, 0x022D, 0x004F, 0x0307, 0x022E, 0x006F, 0x0307, 0x022F,
+	0x022E, 0x0304, 0x0230, 0x022F, 0x0304, 0x0231, 0x0059, 0x0304, 0x0232, 0x0079, 0x0304, 0x0233,
+	0x00A8, 0x0301, 0x0385, 0x0391, 0x0301, 0x0386, 0x0395, 0x0301, 0x0388, 0x0397, 0x0301, 0x0389,
+	0x0399, 0x0301, 0x038A, 0x039F, 0x0301, 0x038C, 0x03A5, 0x0301, 0x038E, 0x03A9, 0x0301, 0x038F,
+	0x03CA, 0x0301, 0x0390, 0x0399, 0x0308, 0x03AA, 0x03A5, 0x0308, 0x03AB, 0x03B1, 0x0301, 0x03AC,
+	0x03B5, 0x0301, 0x03AD, 0x03B7, 0x0301, 0x03AE, 0x03B9, 0x0301, 0x03AF, 0x03CB, 0x0301, 0x03B0,
+	0x03B9, 0x0308, 0x03CA, 0x03C5, 0x0308, 0x03CB, 0x03BF, 0x0301, 0x03CC, 0x03C5, 0x0301, 0x03CD,
+	0x03C9, 0x0301, 0x03CE, 0x03D2, 0x0301, 0x03D3, 0x03D2, 0x0308, 0x03D4, 0x0415, 0x0300, 0x0400,
+	0x0415, 0x0308, 0x0401, 0x0413, 0x0301, 0x0403, 0x0406, 0x0308, 0x0407, 0x041A, 0x0301, 0x040C,
+	0x0418, 0x0300, 0x040D, 0x0423, 0x0306, 0x040E, 0x0418, 0x0306, 0x0419, 0x0438, 0x0306, 0x0439,
+	0x0435, 0x0300, 0x0450, 0x0435, 0x0308, 0x0451, 0x0433, 0x0301, 0x0453, 0x0456, 0x0308, 0x0457,
+	0x043A, 0x0301, 0x045C, 0x0438, 0x0300, 0x045D, 0x0443, 0x0306, 0x045E, 0x0474, 0x030F, 0x0476,
+	0x0475, 0x030F, 0x0477, 0x0416, 0x0306, 0x04C1, 0x0436, 0x0306, 0x04C2, 0x0410, 0x0306, 0x04D0,
+	0x0430, 0x0306, 0x04D1, 0x0410, 0x0308, 0x04D2, 0x0430, 0x0308, 0x04D3, 0x0415, 0x0306, 0x04D6,
+	0x0435, 0x0306, 0x04D7, 0x04D8, 0x0308, 0x04DA, 0x04D9, 0x0308, 0x04DB, 0x0416, 0x0308, 0x04DC,
+	0x0436, 0x0308, 0x04DD, 0x0417, 0x0308, 0x04DE, 0x0437, 0x0308, 0x04DF, 0x0418, 0x0304, 0x04E2,
+	0x0438, 0x0304, 0x04E3, 0x0418, 0x0308, 0x04E4, 0x0438, 0x0308, 0x04E5, 0x041E, 0x0308, 0x04E6,
+	0x043E, 0x0308, 0x04E7, 0x04E8, 0x0308, 0x04EA, 0x04E9, 0x0308, 0x04EB, 0x042D, 0x0308, 0x04EC,
+	0x044D, 0x0308, 0x04ED, 0x0423, 0x0304, 0x04EE, 0x0443, 0x0304, 0x04EF, 0x0423, 0x0308, 0x04F0,
+	0x0443, 0x0308, 0x04F1, 0x0423, 0x030B, 0x04F2, 0x0443, 0x030B, 0x04F3, 0x0427, 0x0308, 0x04F4,
+	0x0447, 0x0308, 0x04F5, 0x042B, 0x0308, 0x04F8, 0x044B, 0x0308, 0x04F9, 0x0627, 0x0653, 0x0622,
+	0x0627, 0x0654, 0x0623, 0x0648, 0x0654, 0x0624, 0x0627, 0x0655, 0x0625, 0x064A, 0x0654, 0x0626,
+	0x06D5, 0x0654, 0x06C0, 0x06C1, 0x0654, 0x06C2, 0x06D2, 0x0654, 0x06D3, 0x0928, 0x093C, 0x0929,
+	0x0930, 0x093C, 0x0931, 0x0933, 0x093C, 0x0934, 0x09C7, 0x09BE, 0x09CB, 0x09C7, 0x09D7, 0x09CC,
+	0x0B47, 0x0B56, 0x0B48, 0x0B47, 0x0B3E, 0x0B4B, 0x0B47, 0x0B57, 0x0B4C, 0x0B92, 0x0BD7, 0x0B94,
+	0x0BC6, 0x0BBE, 0x0BCA, 0x0BC7, 0x0BBE, 0x0BCB, 0x0BC6, 0x0BD7, 0x0BCC, 0x0C46, 0x0C56, 0x0C48,
+	0x0CBF, 0x0CD5, 0x0CC0, 0x0CC6, 0x0CD5, 0x0CC7, 0x0CC6, 0x0CD6, 0x0CC8, 0x0CC6, 0x0CC2, 0x0CCA,
+	0x0CCA, 0x0CD5, 0x0CCB, 0x0D46, 0x0D3E, 0x0D4A, 0x0D47, 0x0D3E, 0x0D4B, 0x0D46, 0x0D57, 0x0D4C,
+	0x0DD9, 0x0DCA, 0x0DDA, 0x0DD9, 0x0DCF, 0x0DDC, 0x0DDC, 0x0DCA, 0x0DDD, 0x0DD9, 0x0DDF, 0x0DDE,
+	0x1025, 0x102E, 0x1026, 0x1B05, 0x1B35, 0x1B06, 0x1B07, 0x1B35, 0x1B08, 0x1B09, 0x1B35, 0x1B0A,
+	0x1B0B, 0x1B35, 0x1B0C, 0x1B0D, 0x1B35, 0x1B0E, 0x1B11, 0x1B35, 0x1B12, 0x1B3A, 0x1B35, 0x1B3B,
+	0x1B3C, 0x1B35, 0x1B3D, 0x1B3E, 0x1B35, 0x1B40, 0x1B3F, 0x1B35, 0x1B41, 0x1B42, 0x1B35, 0x1B43,
+	0x0041, 0x0325, 0x1E00, 0x0061, 0x0325, 0x1E01, 0x0042, 0x0307, 0x1E02, 0x0062, 0x0307, 0x1E03,
+	0x0042, 0x0323, 0x1E04, 0x0062, 0x0323, 0x1E05, 0x0042, 0x0331, 0x1E06, 0x0062, 0x0331, 0x1E07,
+	0x00C7, 0x0301, 0x1E08, 0x00E7, 0x0301, 0x1E09, 0x0044, 0x0307, 0x1E0A, 0x0064, 0x0307, 0x1E0B,
+	0x0044, 0x0323, 0x1E0C, 0x0064, 0x0323, 0x1E0D, 0x0044, 0x0331, 0x1E0E, 0x0064, 0x0331, 0x1E0F,
+	0x0044, 0x0327, 0x1E10, 0x0064, 0x0327, 0x1E11, 0x0044, 0x032D, 0x1E12, 0x0064, 0x032D, 0x1E13,
+	0x0112, 0x0300, 0x1E14, 0x0113, 0x0300, 0x1E15, 0x0112, 0x0301, 0x1E16, 0x0113, 0x0301, 0x1E17,
+	0x0045, 0x032D, 0x1E18, 0x0065, 0x032D, 0x1E19, 0x0045, 0x0330, 0x1E1A, 0x0065, 0x0330, 0x1E1B,
+	0x0228, 0x0306, 0x1E1C, 0x0229, 0x0306, 0x1E1D, 0x0046, 0x0307, 0x1E1E, 0x0066, 0x0307, 0x1E1F,
+	0x0047, 0x0304, 0x1E20, 0x0067, 0x0304, 0x1E21, 0x0048, 0x0307, 0x1E22, 0x0068, 0x0307, 0x1E23,
+	0x0048, 0x0323, 0x1E24, 0x0068, 0x0323, 0x1E25, 0x0048, 0x0308, 0x1E26, 0x0068, 0x0308, 0x1E27,
+	0x0048, 0x0327, 0x1E28, 0x0068, 0x0327, 0x1E29, 0x0048, 0x032E, 0x1E2A, 0x0068, 0x032E, 0x1E2B,
+	0x0049, 0x0330, 0x1E2C, 0x0069, 0x0330, 0x1E2D, 0x00CF, 0x0301, 0x1E2E, 0x00EF, 0x0301, 0x1E2F,
+	0x004B, 0x0301, 0x1E30, 0x006B, 0x0301, 0x1E31, 0x004B, 0x0323, 0x1E32, 0x006B, 0x0323, 0x1E33,
+	0x004B, 0x0331, 0x1E34, 0x006B, 0x0331, 0x1E35, 0x004C, 0x0323, 0x1E36, 0x006C, 0x0323, 0x1E37,
+	0x1E36, 0x0304, 0x1E38, 0x1E37, 0x0304, 0x1E39, 0x004C, 0x0331, 0x1E3A, 0x006C, 0x0331, 0x1E3B,
+	0x004C, 0x032D, 0x1E3C, 0x006C, 0x032D, 0x1E3D, 0x004D, 0x0301, 0x1E3E, 0x006D, 0x0301, 0x1E3F,
+	0x004D, 0x0307, 0x1E40, 0x006D, 0x0307, 0x1E41, 0x004D, 0x0323, 0x1E42, 0x006D, 0x0323, 0x1E43,
+	0x004E, 0x0307, 0x1E44, 0x006E, 0x0307, 0x1E45, 0x004E, 0x0323, 0x1E46, 0x006E, 0x0323, 0x1E47,
+	0x004E, 0x0331, 0x1E48, 0x006E, 0x0331, 0x1E49, 0x004E, 0x032D, 0x1E4A, 0x006E, 0x032D, 0x1E4B,
+	0x00D5, 0x0301, 0x1E4C, 0x00F5, 0x0301, 0x1E4D, 0x00D5, 0x0308, 0x1E4E, 0x00F5, 0x0308, 0x1E4F,
+	0x014C, 0x0300, 0x1E50, 0x014D, 0x0300, 0x1E51, 0x014C, 0x0301, 0x1E52, 0x014D, 0x0301, 0x1E53,
+	0x0050, 0x0301, 0x1E54, 0x0070, 0x0301, 0x1E55, 0x0050, 0x0307, 0x1E56, 0x0070, 0x0307, 0x1E57,
+	0x0052, 0x0307, 0x1E58, 0x0072, 0x0307, 0x1E59, 0x0052, 0x0323, 0x1E5A, 0x0072, 0x0323, 0x1E5B,
+	0x1E5A, 0x0304, 0x1E5C, 0x1E5B, 0x0304, 0x1E5D, 0x0052, 0x0331, 0x1E5E, 0x0072, 0x0331, 0x1E5F,
+	0x0053, 0x0307, 0x1E60, 0x0073, 0x0307, 0x1E61, 0x0053, 0x0323, 0x1E62, 0x0073, 0x0323, 0x1E63,
+	0x015A, 0x0307, 0x1E64, 0x015B, 0x0307, 0x1E65, 0x0160, 0x0307, 0x1E66, 0x0161, 0x0307, 0x1E67,
+	0x1E62, 0x0307, 0x1E68, 0x1E63, 0x0307, 0x1E69, 0x0054, 0x0307, 0x1E6A, 0x0074, 0x0307, 0x1E6B,
+	0x0054, 0x0323, 0x1E6C, 0x0074, 0x0323, 0x1E6D, 0x0054, 0x0331, 0x1E6E, 0x0074, 0x0331, 0x1E6F,
+	0x0054, 0x032D, 0x1E70, 0x0074, 0x032D, 0x1E71, 0x0055, 0x0324, 0x1E72, 0x0075, 0x0324, 0x1E73,
+	0x0055, 0x0330, 0x1E74, 0x0075, 0x0330, 0x1E75, 0x0055, 0x032D, 0x1E76, 0x0075, 0x032D, 0x1E77,
+	0x0168, 0x0301, 0x1E78, 0x0169, 0x0301, 0x1E79, 0x016A, 0x0308, 0x1E7A, 0x016B, 0x0308, 0x1E7B,
+	0x0056, 0x0303, 0x1E7C, 0x0076, 0x0303, 0x1E7D, 0x0056, 0x0323, 0x1E7E, 0x0076, 0x0323, 0x1E7F,
+	0x0057, 0x0300, 0x1E80, 0x0077, 0x0300, 0x1E81, 0x0057, 0x0301, 0x1E82, 0x0077, 0x0301, 0x1E83,
+	0x0057, 0x0308, 0x1E84, 0x0077, 0x0308, 0x1E85, 0x0057, 0x0307, 0x1E86, 0x0077, 0x0307, 0x1E87,
+	0x0057, 0x0323, 0x1E88, 0x0077, 0x0323, 0x1E89, 0x0058, 0x0307, 0x1E8A, 0x0078, 0x0307, 0x1E8B,
+	0x0058, 0x0308, 0x1E8C, 0x0078, 0x0308, 0x1E8D, 0x0059, 0x0307, 0x1E8E, 0x0079, 0x0307, 0x1E8F,
+	0x005A, 0x0302, 0x1E90, 0x007A, 0x0302, 0x1E91, 0x005A, 0x0323, 0x1E92, 0x007A, 0x0323, 0x1E93,
+	0x005A, 0x0331, 0x1E94, 0x007A, 0x0331, 0x1E95, 0x0068, 0x0331, 0x1E96, 0x0074, 0x0308, 0x1E97,
+	0x0077, 0x030A, 0x1E98, 0x0079, 0x030A, 0x1E99, 0x017F, 0x0307, 0x1E9B, 0x0041, 0x0323, 0x1EA0,
+	0x0061, 0x0323, 0x1EA1, 0x0041, 0x0309, 0x1EA2, 0x0061, 0x0309, 0x1EA3, 0x00C2, 0x0301, 0x1EA4,
+	0x00E2, 0x0301, 0x1EA5, 0x00C2, 0x0300, 0x1EA6, 0x00E2, 0x0300, 0x1EA7, 0x00C2, 0x0309, 0x1EA8,
+	0x00E2, 0x0309, 0x1EA9, 0x00C2, 0x0303, 0x1EAA, 0x00E2, 0x0303, 0x1EAB, 0x1EA0, 0x0302, 0x1EAC,
+	0x1EA1, 0x0302, 0x1EAD, 0x0102, 0x0301, 0x1EAE, 0x0103, 0x0301, 0x1EAF, 0x0102, 0x0300, 0x1EB0,
+	0x0103, 0x0300, 0x1EB1, 0x0102, 0x0309, 0x1EB2, 0x0103, 0x0309, 0x1EB3, 0x0102, 0x0303, 0x1EB4,
+	0x0103, 0x0303, 0x1EB5, 0x1EA0, 0x0306, 0x1EB6, 0x1EA1, 0x0306, 0x1EB7, 0x0045, 0x0323, 0x1EB8,
+	0x0065, 0x0323, 0x1EB9, 0x0045, 0x0309, 0x1EBA, 0x0065, 0x0309, 0x1EBB, 0x0045, 0x0303, 0x1EBC,
+	0x0065, 0x0303, 0x1EBD, 0x00CA, 0x0301, 0x1EBE, 0x00EA, 0x0301, 0x1EBF, 0x00CA, 0x0300, 0x1EC0,
+	0x00EA, 0x0300, 0x1EC1, 0x00CA, 0x0309, 0x1EC2, 0x00EA, 0x0309, 0x1EC3, 0x00CA, 0x0303, 0x1EC4,
+	0x00EA, 0x0303, 0x1EC5, 0x1EB8, 0x0302, 0x1EC6, 0x1EB9, 0x0302, 0x1EC7, 0x0049, 0x0309, 0x1EC8,
+	0x0069, 0x0309, 0x1EC9, 0x0049, 0x0323, 0x1ECA, 0x0069, 0x0323, 0x1ECB, 0x004F, 0x0323, 0x1ECC,
+	0x006F, 0x0323, 0x1ECD, 0x004F, 0x0309, 0x1ECE, 0x006F, 0x0309, 0x1ECF, 0x00D4, 0x0301, 0x1ED0,
+	0x00F4, 0x0301, 0x1ED1, 0x00D4, 0x0300, 0x1ED2, 0x00F4, 0x0300, 0x1ED3, 0x00D4, 0x0309, 0x1ED4,
+	0x00F4, 0x0309, 0x1ED5, 0x00D4, 0x0303, 0x1ED6, 0x00F4, 0x0303, 0x1ED7, 0x1ECC, 0x0302, 0x1ED8,
+	0x1ECD, 0x0302, 0x1ED9, 0x01A0, 0x0301, 0x1EDA, 0x01A1, 0x0301, 0x1EDB, 0x01A0, 0x0300, 0x1EDC,
+	0x01A1, 0x0300, 0x1EDD, 0x01A0, 0x0309, 0x1EDE, 0x01A1, 0x0309, 0x1EDF, 0x01A0, 0x0303, 0x1EE0,
+	0x01A1, 0x0303, 0x1EE1, 0x01A0, 0x0323, 0x1EE2, 0x01A1, 0x0323, 0x1EE3, 0x0055, 0x0323, 0x1EE4,
+	0x0075, 0x0323, 0x1EE5, 0x0055, 0x0309, 0x1EE6, 0x0075, 0x0309, 0x1EE7, 0x01AF, 0x0301, 0x1EE8,
+	0x01B0, 0x0301, 0x1EE9, 0x01AF, 0x0300, 0x1EEA, 0x01B0, 0x0300, 0x1EEB, 0x01AF, 0x0309, 0x1EEC,
+	0x01B0, 0x0309, 0x1EED, 0x01AF, 0x0303, 0x1EEE, 0x01B0, 0x0303, 0x1EEF, 0x01AF, 0x0323, 0x1EF0,
+	0x01B0, 0x0323, 0x1EF1, 0x0059, 0x0300, 0x1EF2, 0x0079, 0x0300, 0x1EF3, 0x0059, 0x0323, 0x1EF4,
+	0x0079, 0x0323, 0x1EF5, 0x0059, 0x0309, 0x1EF6, 0x0079, 0x0309, 0x1EF7, 0x0059, 0x0303, 0x1EF8,
+	0x0079, 0x0303, 0x1EF9, 0x03B1, 0x0313, 0x1F00, 0x03B1, 0x0314, 0x1F01, 0x1F00, 0x0300, 0x1F02,
+	0x1F01, 0x0300, 0x1F03, 0x1F00, 0x0301, 0x1F04, 0x1F01, 0x0301, 0x1F05, 0x1F00, 0x0342, 0x1F06,
+	0x1F01, 0x0342, 0x1F07, 0x0391, 0x0313, 0x1F08, 0x0391, 0x0314, 0x1F09, 0x1F08, 0x0300, 0x1F0A,
+	0x1F09, 0x0300, 0x1F0B, 0x1F08, 0x0301, 0x1F0C, 0x1F09, 0x0301, 0x1F0D, 0x1F08, 0x0342, 0x1F0E,
+	0x1F09, 0x0342, 0x1F0F, 0x03B5, 0x0313, 0x1F10, 0x03B5, 0x0314, 0x1F11, 0x1F10, 0x0300, 0x1F12,
+	0x1F11, 0x0300, 0x1F13, 0x1F10, 0x0301, 0x1F14, 0x1F11, 0x0301, 0x1F15, 0x0395, 0x0313, 0x1F18,
+	0x0395, 0x0314, 0x1F19, 0x1F18, 0x0300, 0x1F1A, 0x1F19, 0x0300, 0x1F1B, 0x1F18, 0x0301, 0x1F1C,
+	0x1F19, 0x0301, 0x1F1D, 0x03B7, 0x0313, 0x1F20, 0x03B7, 0x0314, 0x1F21, 0x1F20, 0x0300, 0x1F22,
+	0x1F21, 0x0300, 0x1F23, 0x1F20, 0x0301, 0x1F24, 0x1F21, 0x0301, 0x1F25, 0x1F20, 0x0342, 0x1F26,
+	0x1F21, 0x0342, 0x1F27, 0x0397, 0x0313, 0x1F28, 0x0397, 0x0314, 0x1F29, 0x1F28, 0x0300, 0x1F2A,
+	0x1F29, 0x0300, 0x1F2B, 0x1F28, 0x0301, 0x1F2C, 0x1F29, 0x0301, 0x1F2D, 0x1F28, 0x0342, 0x1F2E,
+	0x1F29, 0x0342, 0x1F2F, 0x03B9, 0x0313, 0x1F30, 0x03B9, 0x0314, 0x1F31, 0x1F30, 0x0300, 0x1F32,
+	0x1F31, 0x0300, 0x1F33, 0x1F30, 0x0301, 0x1F34, 0x1F31, 0x0301, 0x1F35, 0x1F30, 0x0342, 0x1F36,
+	0x1F31, 0x0342, 0x1F37, 0x0399, 0x0313, 0x1F38, 0x0399, 0x0314, 0x1F39, 0x1F38, 0x0300, 0x1F3A,
+	0x1F39, 0x0300, 0x1F3B, 0x1F38, 0x0301, 0x1F3C, 0x1F39, 0x0301, 0x1F3D, 0x1F38, 0x0342, 0x1F3E,
+	0x1F39, 0x0342, 0x1F3F, 0x03BF, 0x0313, 0x1F40, 0x03BF, 0x0314, 0x1F41, 0x1F40, 0x0300, 0x1F42,
+	0x1F41, 0x0300, 0x1F43, 0x1F40, 0x0301, 0x1F44, 0x1F41, 0x0301, 0x1F45, 0x039F, 0x0313, 0x1F48,
+	0x039F, 0x0314, 0x1F49, 0x1F48, 0x0300, 0x1F4A, 0x1F49, 0x0300, 0x1F4B, 0x1F48, 0x0301, 0x1F4C,
+	0x1F49, 0x0301, 0x1F4D, 0x03C5, 0x0313, 0x1F50, 0x03C5, 0x0314, 0x1F51, 0x1F50, 0x0300, 0x1F52,
+	0x1F51, 0x0300, 0x1F53, 0x1F50, 0x0301, 0x1F54, 0x1F51, 0x0301, 0x1F55, 0x1F50, 0x0342, 0x1F56,
+	0x1F51, 0x0342, 0x1F57, 0x03A5, 0x0314, 0x1F59, 0x1F59, 0x0300, 0x1F5B, 0x1F59, 0x0301, 0x1F5D,
+	0x1F59, 0x0342, 0x1F5F, 0x03C9, 0x0313, 0x1F60, 0x03C9, 0x0314, 0x1F61, 0x1F60, 0x0300, 0x1F62,
+	0x1F61, 0x0300, 0x1F63, 0x1F60, 0x0301, 0x1F64, 0x1F61, 0x0301, 0x1F65, 0x1F60, 0x0342, 0x1F66,
+	0x1F61, 0x0342, 0x1F67, 0x03A9, 0x0313, 0x1F68, 0x03A9, 0x0314, 0x1F69, 0x1F68, 0x0300, 0x1F6A,
+	0x1F69, 0x0300, 0x1F6B, 0x1F68, 0x0301, 0x1F6C, 0x1F69, 0x0301, 0x1F6D, 0x1F68, 0x0342, 0x1F6E,
+	0x1F69, 0x0342, 0x1F6F, 0x03B1, 0x0300, 0x1F70, 0x03B5, 0x0300, 0x1F72, 0x03B7, 0x0300, 0x1F74,
+	0x03B9, 0x0300, 0x1F76, 0x03BF, 0x0300, 0x1F78, 0x03C5, 0x0300, 0x1F7A, 0x03C9, 0x0300, 0x1F7C,
+	0x1F00, 0x0345, 0x1F80, 0x1F01, 0x0345, 0x1F81, 0x1F02, 0x0345, 0x1F82, 0x1F03, 0x0345, 0x1F83,
+	0x1F04, 0x0345, 0x1F84, 0x1F05, 0x0345, 0x1F85, 0x1F06, 0x0345, 0x1F86, 0x1F07, 0x0345, 0x1F87,
+	0x1F08, 0x0345, 0x1F88, 0x1F09, 0x0345, 0x1F89, 0x1F0A, 0x0345, 0x1F8A, 0x1F0B, 0x0345, 0x1F8B,
+	0x1F0C, 0x0345, 0x1F8C, 0x1F0D, 0x0345, 0x1F8D, 0x1F0E, 0x0345, 0x1F8E, 0x1F0F, 0x0345, 0x1F8F,
+	0x1F20, 0x0345, 0x1F90, 0x1F21, 0x0345, 0x1F91, 0x1F22, 0x0345, 0x1F92, 0x1F23, 0x0345, 0x1F93,
+	0x1F24, 0x0345, 0x1F94, 0x1F25, 0x0345, 0x1F95, 0x1F26, 0x0345, 0x1F96, 0x1F27, 0x0345, 0x1F97,
+	0x1F28, 0x0345, 0x1F98, 0x1F29, 0x0345, 0x1F99, 0x1F2A, 0x0345, 0x1F9A, 0x1F2B, 0x0345, 0x1F9B,
+	0x1F2C, 0x0345, 0x1F9C, 0x1F2D, 0x0345, 0x1F9D, 0x1F2E, 0x0345, 0x1F9E, 0x1F2F, 0x0345, 0x1F9F,
+	0x1F60, 0x0345, 0x1FA0, 0x1F61, 0x0345, 0x1FA1, 0x1F62, 0x0345, 0x1FA2, 0x1F63, 0x0345, 0x1FA3,
+	0x1F64, 0x0345, 0x1FA4, 0x1F65, 0x0345, 0x1FA5, 0x1F66, 0x0345, 0x1FA6, 0x1F67, 0x0345, 0x1FA7,
+	0x1F68, 0x0345, 0x1FA8, 0x1F69, 0x0345, 0x1FA9, 0x1F6A, 0x0345, 0x1FAA, 0x1F6B, 0x0345, 0x1FAB,
+	0x1F6C, 0x0345, 0x1FAC, 0x1F6D, 0x0345, 0x1FAD, 0x1F6E, 0x0345, 0x1FAE, 0x1F6F, 0x0345, 0x1FAF,
+	0x03B1, 0x0306, 0x1FB0, 0x03B1, 0x0304, 0x1FB1, 0x1F70, 0x0345, 0x1FB2, 0x03B1, 0x0345, 0x1FB3,
+	0x03AC, 0x0345, 0x1FB4, 0x03B1, 0x0342, 0x1FB6, 0x1FB6, 0x0345, 0x1FB7, 0x0391, 0x0306, 0x1FB8,
+	0x0391, 0x0304, 0x1FB9, 0x0391, 0x0300, 0x1FBA, 0x0391, 0x0345, 0x1FBC, 0x00A8, 0x0342, 0x1FC1,
+	0x1F74, 0x0345, 0x1FC2, 0x03B7, 0x0345, 0x1FC3, 0x03AE, 0x0345, 0x1FC4, 0x03B7, 0x0342, 0x1FC6,
+	0x1FC6, 0x0345, 0x1FC7, 0x0395, 0x0300, 0x1FC8, 0x0397, 0x0300, 0x1FCA, 0x0397, 0x0345, 0x1FCC,
+	0x1FBF, 0x0300, 0x1FCD, 0x1FBF, 0x0301, 0x1FCE, 0x1FBF, 0x0342, 0x1FCF, 0x03B9, 0x0306, 0x1FD0,
+	0x03B9, 0x0304, 0x1FD1, 0x03CA, 0x0300, 0x1FD2, 0x03B9, 0x0342, 0x1FD6, 0x03CA, 0x0342, 0x1FD7,
+	0x0399, 0x0306, 0x1FD8, 0x0399, 0x0304, 0x1FD9, 0x0399, 0x0300, 0x1FDA, 0x1FFE, 0x0300, 0x1FDD,
+	0x1FFE, 0x0301, 0x1FDE, 0x1FFE, 0x0342, 0x1FDF, 0x03C5, 0x0306, 0x1FE0, 0x03C5, 0x0304, 0x1FE1,
+	0x03CB, 0x0300, 0x1FE2, 0x03C1, 0x0313, 0x1FE4, 0x03C1, 0x0314, 0x1FE5, 0x03C5, 0x0342, 0x1FE6,
+	0x03CB, 0x0342, 0x1FE7, 0x03A5, 0x0306, 0x1FE8, 0x03A5, 0x0304, 0x1FE9, 0x03A5, 0x0300, 0x1FEA,
+	0x03A1, 0x0314, 0x1FEC, 0x00A8, 0x0300, 0x1FED, 0x1F7C, 0x0345, 0x1FF2, 0x03C9, 0x0345, 0x1FF3,
+	0x03CE, 0x0345, 0x1FF4, 0x03C9, 0x0342, 0x1FF6, 0x1FF6, 0x0345, 0x1FF7, 0x039F, 0x0300, 0x1FF8,
+	0x03A9, 0x0300, 0x1FFA, 0x03A9, 0x0345, 0x1FFC, 0x2190, 0x0338, 0x219A, 0x2192, 0x0338, 0x219B,
+	0x2194, 0x0338, 0x21AE, 0x21D0, 0x0338, 0x21CD, 0x21D4, 0x0338, 0x21CE, 0x21D2, 0x0338, 0x21CF,
+	0x2203, 0x0338, 0x2204, 0x2208, 0x0338, 0x2209, 0x220B, 0x0338, 0x220C, 0x2223, 0x0338, 0x2224,
+	0x2225, 0x0338, 0x2226, 0x223C, 0x0338, 0x2241, 0x2243, 0x0338, 0x2244, 0x2245, 0x0338, 0x2247,
+	0x2248, 0x0338, 0x2249, 0x003D, 0x0338, 0x2260, 0x2261, 0x0338, 0x2262, 0x224D, 0x0338, 0x226D,
+	0x003C, 0x0338, 0x226E, 0x003E, 0x0338, 0x226F, 0x2264, 0x0338, 0x2270, 0x2265, 0x0338, 0x2271,
+	0x2272, 0x0338, 0x2274, 0x2273, 0x0338, 0x2275, 0x2276, 0x0338, 0x2278, 0x2277, 0x0338, 0x2279,
+	0x227A, 0x0338, 0x2280, 0x227B, 0x0338, 0x2281, 0x2282, 0x0338, 0x2284, 0x2283, 0x0338, 0x2285,
+	0x2286, 0x0338, 0x2288, 0x2287, 0x0338, 0x2289, 0x22A2, 0x0338, 0x22AC, 0x22A8, 0x0338, 0x22AD,
+	0x22A9, 0x0338, 0x22AE, 0x22AB, 0x0338, 0x22AF, 0x227C, 0x0338, 0x22E0, 0x227D, 0x0338, 0x22E1,
+	0x2291, 0x0338, 0x22E2, 0x2292, 0x0338, 0x22E3, 0x22B2, 0x0338, 0x22EA, 0x22B3, 0x0338, 0x22EB,
+	0x22B4, 0x0338, 0x22EC, 0x22B5, 0x0338, 0x22ED, 0x304B, 0x3099, 0x304C, 0x304D, 0x3099, 0x304E,
+	0x304F, 0x3099, 0x3050, 0x3051, 0x3099, 0x3052, 0x3053, 0x3099, 0x3054, 0x3055, 0x3099, 0x3056,
+	0x3057, 0x3099, 0x3058, 0x3059, 0x3099, 0x305A, 0x305B, 0x3099, 0x305C, 0x305D, 0x3099, 0x305E,
+	0x305F, 0x3099, 0x3060, 0x3061, 0x3099, 0x3062, 0x3064, 0x3099, 0x3065, 0x3066, 0x3099, 0x3067,
+	0x3068, 0x3099, 0x3069, 0x306F, 0x3099, 0x3070, 0x306F, 0x309A, 0x3071, 0x3072, 0x3099, 0x3073,
+	0x3072, 0x309A, 0x3074, 0x3075, 0x3099, 0x3076, 0x3075, 0x309A, 0x3077, 0x3078, 0x3099, 0x3079,
+	0x3078, 0x309A, 0x307A, 0x307B, 0x3099, 0x307C, 0x307B, 0x309A, 0x307D, 0x3046, 0x3099, 0x3094,
+	0x309D, 0x3099, 0x309E, 0x30AB, 0x3099, 0x30AC, 0x30AD, 0x3099, 0x30AE, 0x30AF, 0x3099, 0x30B0,
+	0x30B1, 0x3099, 0x30B2, 0x30B3, 0x3099, 0x30B4, 0x30B5, 0x3099, 0x30B6, 0x30B7, 0x3099, 0x30B8,
+	0x30B9, 0x3099, 0x30BA, 0x30BB, 0x3099, 0x30BC, 0x30BD, 0x3099, 0x30BE, 0x30BF, 0x3099, 0x30C0,
+	0x30C1, 0x3099, 0x30C2, 0x30C4, 0x3099, 0x30C5, 0x30C6, 0x3099, 0x30C7, 0x30C8, 0x3099, 0x30C9,
+	0x30CF, 0x3099, 0x30D0, 0x30CF, 0x309A, 0x30D1, 0x30D2, 0x3099, 0x30D3, 0x30D2, 0x309A, 0x30D4,
+	0x30D5, 0x3099, 0x30D6, 0x30D5, 0x309A, 0x30D7, 0x30D8, 0x3099, 0x30D9, 0x30D8, 0x309A, 0x30DA,
+	0x30DB, 0x3099, 0x30DC, 0x30DB, 0x309A, 0x30DD, 0x30A6, 0x3099, 0x30F4, 0x30EF, 0x3099, 0x30F7,
+	0x30F0, 0x3099, 0x30F8, 0x30F1, 0x3099, 0x30F9, 0x30F2, 0x3099, 0x30FA, 0x30FD, 0x3099, 0x30FE,
+	0x11099, 0x110BA, 0x1109A, 0x1109B, 0x110BA, 0x1109C, 0x110A5, 0x110BA, 0x110AB, 0x11131, 0x11127, 0x1112E,
+	0x11132, 0x11127, 0x1112F, 0x11347, 0x1133E, 0x1134B, 0x11347, 0x11357, 0x1134C, 0x114B9, 0x114BA, 0x114BB,
+	0x114B9, 0x114B0, 0x114BC, 0x114B9, 0x114BD, 0x114BE, 0x115B8, 0x115AF, 0x115BA, 0x115B9, 0x115AF, 0x115BB,
+	0x11935, 0x11930, 0x11938,
+}
+
+// Ranges of characters and their canonical combining class, as first, last,
+// class, taken from the Unicode Character Database. Anything not in here is
+// of class 0.
+var combiningTable = []int{
+	0x0300, 0x0314, 230, 0x0315, 0x0315, 232, 0x0316, 0x0319, 220, 0x031A, 0x031A, 232,
+	0x031B, 0x031B, 216, 0x031C, 0x0320, 220, 0x0321, 0x0322, 202, 0x0323, 0x0326, 220,
+	0x0327, 0x0328, 202, 0x0329, 0x0333, 220, 0x0334, 0x0338, 1, 0x0339, 0x033C, 220,
+	0x033D, 0x0344, 230, 0x0345, 0x0345, 240, 0x0346, 0x0346, 230, 0x0347, 0x0349, 220,
+	0x034A, 0x034C, 230, 0x034D, 0x034E, 220, 0x0350, 0x0352, 230, 0x0353, 0x0356, 220,
+	0x0357, 0x0357, 230, 0x0358, 0x0358, 232, 0x0359, 0x035A, 220, 0x035B, 0x035B, 230,
+	0x035C, 0x035C, 233, 0x035D, 0x035E, 234, 0x035F, 0x035F, 233, 0x0360, 0x0361, 234,
+	0x0362, 0x0362, 233, 0x0363, 0x036F, 230, 0x0483, 0x0487, 230, 0x0591, 0x0591, 220,
+	0x0592, 0x0595, 230, 0x0596, 0x0596, 220, 0x0597, 0x0599, 230, 0x059A, 0x059A, 222,
+	0x059B, 0x059B, 220, 0x059C, 0x05A1, 230, 0x05A2, 0x05A7, 220, 0x05A8, 0x05A9, 230,
+	0x05AA, 0x05AA, 220, 0x05AB, 0x05AC, 230, 0x05AD, 0x05AD, 222, 0x05AE, 0x05AE, 228,
+	0x05AF, 0x05AF, 230, 0x05B0, 0x05B0, 10, 0x05B1, 0x05B1, 11, 0x05B2, 0x05B2, 12,
+	0x05B3, 0x05B3, 13, 0x05B4, 0x05B4, 14, 0x05B5, 0x05B5, 15, 0x05B6, 0x05B6, 16,
+	0x05B7, 0x05B7, 17, 0x05B8, 0x05B8, 18, 0x05B9, 0x05BA, 19, 0x05BB, 0x05BB, 20,
+	0x05BC, 0x05BC, 21, 0x05BD, 0x05BD, 22, 0x05BF, 0x05BF, 23, 0x05C1, 0x05C1, 24,
+	0x05C2, 0x05C2, 25, 0x05C4, 0x05C4, 230, 0x05C5, 0x05C5, 220, 0x05C7, 0x05C7, 18,
+	0x0610, 0x0617, 230, 0x0618, 0x0618, 30, 0x0619, 0x0619, 31, 0x061A, 0x061A, 32,
+	0x064B, 0x064B, 27, 0x064C, 0x064C, 28, 0x064D, 0x064D, 29, 0x064E, 0x064E, 30,
+	0x064F, 0x064F, 31, 0x0650, 0x0650, 32, 0x0651, 0x0651, 33, 0x0652, 0x0652, 34,
+	0x0653, 0x0654, 230, 0x0655, 0x0656, 220, 0x0657, 0x065B, 230, 0x065C, 0x065C, 220,
+	0x065D, 0x065E, 230, 0x065F, 0x065F, 220, 0x0670, 0x0670, 35, 0x06D6, 0x06DC, 230,
+	0x06DF, 0x06E2, 230, 0x06E3, 0x06E3, 220, 0x06E4, 0x06E4, 230, 0x06E7, 0x06E8, 230,
+	0x06EA, 0x06EA, 220, 0x06EB, 0x06EC, 230, 0x06ED, 0x06ED, 220, 0x0711, 0x0711, 36,
+	0x0730, 0x0730, 230, 0x0731, 0x0731, 220, 0x0732, 0x0733, 230, 0x0734, 0x0734, 220,
+	0x0735, 0x0736, 230, 0x0737, 0x0739, 220, 0x073A, 0x073A, 230, 0x073B, 0x073C, 220,
+	0x073D, 0x073D, 230, 0x073E, 0x073E, 220, 0x073F, 0x0741, 230, 0x0742, 0x0742, 220,
+	0x0743, 0x0743, 230, 0x0744, 0x0744, 220, 0x0745, 0x0745, 230, 0x0746, 0x0746, 220,
+	0x0747, 0x0747, 230, 0x0748, 0x0748, 220, 0x0749, 0x074A, 230, 0x07EB, 0x07F1, 230,
+	0x07F2, 0x07F2, 220, 0x07F3, 0x07F3, 230, 0x07FD, 0x07FD, 220, 0x0816, 0x0819, 230,
+	0x081B, 0x0823, 230, 0x0825, 0x0827, 230, 0x0829, 0x082D, 230, 0x0859, 0x085B, 220,
+	0x0898, 0x0898, 230, 0x0899, 0x089B, 220, 0x089C, 0x089F, 230, 0x08CA, 0x08CE, 230,
+	0x08CF, 0x08D3, 220, 0x08D4, 0x08E1, 230, 0x08E3, 0x08E3, 220, 0x08E4, 0x08E5, 230,
+	0x08E6, 0x08E6, 220, 0x08E7, 0x08E8, 230, 0x08E9, 0x08E9, 220, 0x08EA, 0x08EC, 230,
+	0x08ED, 0x08EF, 220, 0x08F0, 0x08F0, 27, 0x08F1, 0x08F1, 28, 0x08F2, 0x08F2, 29,
+	0x08F3, 0x08F5, 230, 0x08F6, 0x08F6, 220, 0x08F7, 0x08F8, 230, 0x08F9, 0x08FA, 220,
+	0x08FB, 0x08FF, 230, 0x093C, 0x093C, 7, 0x094D, 0x094D, 9, 0x0951, 0x0951, 230,
+	0x0952, 0x0952, 220, 0x0953, 0x0954, 230, 0x09BC, 0x09BC, 7, 0x09CD, 0x09CD, 9,
+	0x09FE, 0x09FE, 230, 0x0A3C, 0x0A3C, 7, 0x0A4D, 0x0A4D, 9, 0x0ABC, 0x0ABC, 7,
+	0x0ACD, 0x0ACD, 9, 0x0B3C, 0x0B3C, 7, 0x0B4D, 0x0B4D, 9, 0x0BCD, 0x0BCD, 9,
+	0x0C3C, 0x0C3C, 7, 0x0C4D, 0x0C4D, 9, 0x0C55, 0x0C55, 84, 0x0C56, 0x0C56, 91,
+	0x0CBC, 0x0CBC, 7, 0x0CCD, 0x0CCD, 9, 0x0D3B, 0x0D3C, 9, 0x0D4D, 0x0D4D, 9,
+	0x0DCA, 0x0DCA, 9, 0x0E38, 0x0E39, 103, 0x0E3A, 0x0E3A, 9, 0x0E48, 0x0E4B, 107,
+	0x0EB8, 0x0EB9, 118, 0x0EBA, 0x0EBA, 9, 0x0EC8, 0x0ECB, 122, 0x0F18, 0x0F19, 220,
+	0x0F35, 0x0F35, 220, 0x0F37, 0x0F37, 220, 0x0F39, 0x0F39, 216, 0x0F71, 0x0F71, 129,
+	0x0F72, 0x0F72, 130, 0x0F74, 0x0F74, 132, 0x0F7A, 0x0F7D, 130, 0x0F80, 0x0F80, 130,
+	0x0F82, 0x0F83, 230, 0x0F84, 0x0F84, 9, 0x0F86, 0x0F87, 230, 0x0FC6, 0x0FC6, 220,
+	0x1037, 0x1037, 7, 0x1039, 0x103A, 9, 0x108D, 0x108D, 220, 0x135D, 0x135F, 230,
+	0x1714, 0x1715, 9, 0x1734, 0x1734, 9, 0x17D2, 0x17D2, 9, 0x17DD, 0x17DD, 230,
+	0x18A9, 0x18A9, 228, 0x1939, 0x1939, 222, 0x193A, 0x193A, 230, 0x193B, 0x193B, 220,
+	0x1A17, 0x1A17, 230, 0x1A18, 0x1A18, 220, 0x1A60, 0x1A60, 9, 0x1A75, 0x1A7C, 230,
+	0x1A7F, 0x1A7F, 220, 0x1AB0, 0x1AB4, 230, 0x1AB5, 0x1ABA, 220, 0x1ABB, 0x1ABC, 230,
+	0x1ABD, 0x1ABD, 220, 0x1ABF, 0x1AC0, 220, 0x1AC1, 0x1AC2, 230, 0x1AC3, 0x1AC4, 220,
+	0x1AC5, 0x1AC9, 230, 0x1ACA, 0x1ACA, 220, 0x1ACB, 0x1ACE, 230, 0x1B34, 0x1B34, 7,
+	0x1B44, 0x1B44, 9, 0x1B6B, 0x1B6B, 230, 0x1B6C, 0x1B6C, 220, 0x1B6D, 0x1B73, 230,
+	0x1BAA, 0x1BAB, 9, 0x1BE6, 0x1BE6, 7, 0x1BF2, 0x1BF3, 9, 0x1C37, 0x1C37, 7,
+	0x1CD0, 0x1CD2, 230, 0x1CD4, 0x1CD4, 1, 0x1CD5, 0x1CD9, 220, 0x1CDA, 0x1CDB, 230,
+	0x1CDC, 0x1CDF, 220, 0x1CE0, 0x1CE0, 230, 0x1CE2, 0x1CE8, 1, 0x1CED, 0x1CED, 220,
+	0x1CF4, 0x1CF4, 230, 0x1CF8, 0x1CF9, 230, 0x1DC0, 0x1DC1, 230, 0x1DC2, 0x1DC2, 220,
+	0x1DC3, 0x1DC9, 230, 0x1DCA, 0x1DCA, 220, 0x1DCB, 0x1DCC, 230, 0x1DCD, 0x1DCD, 234,
+	0x1DCE, 0x1DCE, 214, 0x1DCF, 0x1DCF, 220, 0x1DD0, 0x1DD0, 202, 0x1DD1, 0x1DF5, 230,
+	0x1DF6, 0x1DF6, 232, 0x1DF7, 0x1DF8, 228, 0x1DF9, 0x1DF9, 220, 0x1DFA, 0x1DFA, 218,
+	0x1DFB, 0x1DFB, 230, 0x1DFC, 0x1DFC, 233, 0x1DFD, 0x1DFD, 220, 0x1DFE, 0x1DFE, 230,
+	0x1DFF, 0x1DFF, 220, 0x20D0, 0x20D1, 230, 0x20D2, 0x20D3, 1, 0x20D4, 0x20D7, 230,
+	0x20D8, 0x20DA, 1, 0x20DB, 0x20DC, 230, 0x20E1, 0x20E1, 230, 0x20E5, 0x20E6, 1,
+	0x20E7, 0x20E7, 230, 0x20E8, 0x20E8, 220, 0x20E9, 0x20E9, 230, 0x20EA, 0x20EB, 1,
+	0x20EC, 0x20EF, 220, 0x20F0, 0x20F0, 230, 0x2CEF, 0x2CF1, 230, 0x2D7F, 0x2D7F, 9,
+	0x2DE0, 0x2DFF, 230, 0x302A, 0x302A, 218, 0x302B, 0x302B, 228, 0x302C, 0x302C, 232,
+	0x302D, 0x302D, 222, 0x302E, 0x302F, 224, 0x3099, 0x309A, 8, 0xA66F, 0xA66F, 230,
+	0xA674, 0xA67D, 230, 0xA69E, 0xA69F, 230, 0xA6F0, 0xA6F1, 230, 0xA806, 0xA806, 9,
+	0xA82C, 0xA82C, 9, 0xA8C4, 0xA8C4, 9, 0xA8E0, 0xA8F1, 230, 0xA92B, 0xA92D, 220,
+	0xA953, 0xA953, 9, 0xA9B3, 0xA9B3, 7, 0xA9C0, 0xA9C0, 9, 0xAAB0, 0xAAB0, 230,
+	0xAAB2, 0xAAB3, 230, 0xAAB4, 0xAAB4, 220, 0xAAB7, 0xAAB8, 230, 0xAABE, 0xAABF, 230,
+	0xAAC1, 0xAAC1, 230, 0xAAF6, 0xAAF6, 9, 0xABED, 0xABED, 9, 0xFB1E, 0xFB1E, 26,
+	0xFE20, 0xFE26, 230, 0xFE27, 0xFE2D, 220, 0xFE2E, 0xFE2F, 230, 0x101FD, 0x101FD, 220,
+	0x102E0, 0x102E0, 220, 0x10376, 0x1037A, 230, 0x10A0D, 0x10A0D, 220, 0x10A0F, 0x10A0F, 230,
+	0x10A38, 0x10A38, 230, 0x10A39, 0x10A39, 1, 0x10A3A, 0x10A3A, 220, 0x10A3F, 0x10A3F, 9,
+	0x10AE5, 0x10AE5, 230, 0x10AE6, 0x10AE6, 220, 0x10D24, 0x10D27, 230, 0x10EAB, 0x10EAC, 230,
+	0x10F46, 0x10F47, 220, 0x10F48, 0x10F4A, 230, 0x10F4B, 0x10F4B, 220, 0x10F4C, 0x10F4C, 230,
+	0x10F4D, 0x10F50, 220, 0x10F82, 0x10F82, 230, 0x10F83, 0x10F83, 220, 0x10F84, 0x10F84, 230,
+	0x10F85, 0x10F85, 220, 0x11046, 0x11046, 9, 0x11070, 0x11070, 9, 0x1107F, 0x1107F, 9,
+	0x110B9, 0x110B9, 9, 0x110BA, 0x110BA, 7, 0x11100, 0x11102, 230, 0x11133, 0x11134, 9,
+	0x11173, 0x11173, 7, 0x111C0, 0x111C0, 9, 0x111CA, 0x111CA, 7, 0x11235, 0x11235, 9,
+	0x11236, 0x11236, 7, 0x112E9, 0x112E9, 7, 0x112EA, 0x112EA, 9, 0x1133B, 0x1133C, 7,
+	0x1134D, 0x1134D, 9, 0x11366, 0x1136C, 230, 0x11370, 0x11374, 230, 0x11442, 0x11442, 9,
+	0x11446, 0x11446, 7, 0x1145E, 0x1145E, 230, 0x114C2, 0x114C2, 9, 0x114C3, 0x114C3, 7,
+	0x115BF, 0x115BF, 9, 0x115C0, 0x115C0, 7, 0x1163F, 0x1163F, 9, 0x116B6, 0x116B6, 9,
+	0x116B7, 0x116B7, 7, 0x1172B, 0x1172B, 9, 0x11839, 0x11839, 9, 0x1183A, 0x1183A, 7,
+	0x1193D, 0x1193E, 9, 0x11943, 0x11943, 7, 0x119E0, 0x119E0, 9, 0x11A34, 0x11A34, 9,
+	0x11A47, 0x11A47, 9, 0x11A99, 0x11A99, 9, 0x11C3F, 0x11C3F, 9, 0x11D42, 0x11D42, 7,
+	0x11D44, 0x11D45, 9, 0x11D97, 0x11D97, 9, 0x16AF0, 0x16AF4, 1, 0x16B30, 0x16B36, 230,
+	0x16FF0, 0x16FF1, 6, 0x1BC9E, 0x1BC9E, 1, 0x1D165, 0x1D166, 216, 0x1D167, 0x1D169, 1,
+	0x1D16D, 0x1D16D, 226, 0x1D16E, 0x1D172, 216, 0x1D17B, 0x1D182, 220, 0x1D185, 0x1D189, 230,
+	0x1D18A, 0x1D18B, 220, 0x1D1AA, 0x1D1AD, 230, 0x1D242, 0x1D244, 230, 0x1E000, 0x1E006, 230,
+	0x1E008, 0x1E018, 230, 0x1E01B, 0x1E021, 230, 0x1E023, 0x1E024, 230, 0x1E026, 0x1E02A, 230,
+	0x1E130, 0x1E136, 230, 0x1E2AE, 0x1E2AE, 230, 0x1E2EC, 0x1E2EF, 230, 0x1E8D0, 0x1E8D6, 220,
+	0x1E944, 0x1E949, 230, 0x1E94A, 0x1E94A, 7,
+}
+
+// What characters with compatibility decompositions come to once
+// decomposed, marks left out, taken from the Unicode Character Database:
+// Ligatures, full and half width forms, superscripts, letterlike symbols
+// and the like. Those outside the Latin, punctuation, symbol and width
+// blocks are left as they are.
+var compatibilityTable = map[int]string{
+	0x00A0: " ", 0x00A8: " ", 0x00AA: "a", 0x00AF: " ", 0x00B2: "2", 0x00B3: "3", 0x00B4: " ",
+	0x00B5: "\u03BC", 0x00B8: " ", 0x00B9: "1", 0x00BA: "o", 0x00BC: "1\u20444", 0x00BD: "1\u20442",
+	0x00BE: "3\u20444", 0x0132: "IJ", 0x0133: "ij", 0x013F: "L\u00B7", 0x0140: "l\u00B7",
+	0x0149: "\u02BCn", 0x017F: "s", 0x01C4: "DZ", 0x01C5: "Dz", 0x01C6: "dz", 0x01C7: "LJ",
+	0x01C8: "Lj", 0x01C9: "lj", 0x01CA: "NJ", 0x01CB: "Nj", 0x01CC: "nj", 0x01F1: "DZ", 0x01F2: "Dz",
+	0x01F3: "dz", 0x02B0: "h", 0x02B1: "\u0266", 0x02B2: "j", 0x02B3: "r", 0x02B4: "\u0279",
+	0x02B5: "\u027B", 0x02B6: "\u0281", 0x02B7: "w", 0x02B8: "y", 0x02D8: " ", 0x02D9: " ",
+	0x02DA: " ", 0x02DB: " ", 0x02DC: " ", 0x02DD: " ", 0x02E0: "\u0263", 0x02E1: "l", 0x02E2: "s",
+	0x02E3: "x", 0x02E4: "\u0295", 0x1D2C: "A", 0x1D2D: "\u00C6", 0x1D2E: "B", 0x1D30: "D",
+	0x1D31: "E", 0x1D32: "\u018E", 0x1D33: "G", 0x1D34: "H", 0x1D35: "I", 0x1D36: "J", 0x1D37: "K",
+	0x1D38: "L", 0x1D39: "M", 0x1D3A: "N", 0x1D3C: "O", 0x1D3D: "\u0222", 0x1D3E: "P", 0x1D3F: "R",
+	0x1D40: "T", 0x1D41: "U", 0x1D42: "W", 0x1D43: "a", 0x1D44: "\u0250", 0x1D45: "\u0251",
+	0x1D46: "\u1D02", 0x1D47: "b", 0x1D48: "d", 0x1D49: "e", 0x1D4A: "\u0259", 0x1D4B: "\u025B",
+	0x1D4C: "\u025C", 0x1D4D: "g", 0x1D4F: "k", 0x1D50: "m", 0x1D51: "\u014B", 0x1D52: "o",
+	0x1D53: "\u0254", 0x1D54: "\u1D16", 0x1D55: "\u1D17", 0x1D56: "p", 0x1D57: "t", 0x1D58: "u",
+	0x1D59: "\u1D1D", 0x1D5A: "\u026F", 0x1D5B: "v", 0x1D5C: "\u1D25", 0x1D5D: "\u03B2",
+	0x1D5E: "\u03B3", 0x1D5F: "\u03B4", 0x1D60: "\u03C6", 0x1D61: "\u03C7", 0x1D62: "i", 0x1D63: "r",
+	0x1D64: "u", 0x1D65: "v", 0x1D66: "\u03B2", 0x1D67: "\u03B3", 0x1D68: "\u03C1", 0x1D69: "\u03C6",
+	0x1D6A: "\u03C7", 0x1D78: "\u043D", 0x1D9B: "\u0252", 0x1D9C: "c", 0x1D9D: "\u0255",
+	0x1D9E: "\u00F0", 0x1D9F: "\u025C", 0x1DA0: "f", 0x1DA1: "\u025F", 0x1DA2: "\u0261",
+	0x1DA3: "\u0265", 0x1DA4: "\u0268", 0x1DA5: "\u0269", 0x1DA6: "\u026A", 0x1DA7: "\u1D7B",
+	0x1DA8: "\u029D", 0x1DA9: "\u026D", 0x1DAA: "\u1D85", 0x1DAB: "\u029F", 0x1DAC: "\u0271",
+	0x1DAD: "\u0270", 0x1DAE: "\u0272", 0x1DAF: "\u0273", 0x1DB0: "\u0274", 0x1DB1: "\u0275",
+	0x1DB2: "\u0278", 0x1DB3: "\u0282", 0x1DB4: "\u0283", 0x1DB5: "\u01AB", 0x1DB6: "\u0289",
+	0x1DB7: "\u028A", 0x1DB8: "\u1D1C", 0x1DB9: "\u028B", 0x1DBA: "\u028C", 0x1DBB: "z",
+	0x1DBC: "\u0290", 0x1DBD: "\u0291", 0x1DBE: "\u0292", 0x1DBF: "\u03B8", 0x2002: " ", 0x2003: " ",
+	0x2004: " ", 0x2005: " ", 0x2006: " ", 0x2007: " ", 0x2008: " ", 0x2009: " ", 0x200A: " ",
+	0x2011: "\u2010", 0x2017: " ", 0x2024: ".", 0x2025: "..", 0x2026: "...", 0x202F: " ",
+	0x2033: "\u2032\u2032", 0x2034: "\u2032\u2032\u2032", 0x2036: "\u2035\u2035",
+	0x2037: "\u2035\u2035\u2035", 0x203C: "!!", 0x203E: " ", 0x2047: "??", 0x2048: "?!", 0x2049: "!?",
+	0x2057: "\u2032\u2032\u2032\u2032", 0x205F: " ", 0x2070: "0", 0x2071: "i", 0x2074: "4",
+	0x2075: "5", 0x2076: "6", 0x2077: "7", 0x2078: "8", 0x2079: "9", 0x207A: "+", 0x207B: "\u2212",
+	0x207C: "=", 0x207D: "(", 0x207E: ")", 0x207F: "n", 0x2080: "0", 0x2081: "1", 0x2082: "2",
+	0x2083: "3", 0x2084: "4", 0x2085: "5", 0x2086: "6", 0x2087: "7", 0x2088: "8", 0x2089: "9",
+	0x208A: "+", 0x208B: "\u2212", 0x208C: "=", 0x208D: "(", 0x208E: ")", 0x2090: "a", 0x2091: "e",
+	0x2092: "o", 0x2093: "x", 0x2094: "\u0259", 0x2095: "h", 0x2096: "k", 0x2097: "l", 0x2098: "m",
+	0x2099: "n", 0x209A: "p", 0x209B: "s", 0x209C: "t", 0x2100: "a/c", 0x2101: "a/s", 0x2102: "C",
+	0x2103: "\u00B0C", 0x2105: "c/o", 0x2106: "c/u", 0x2107: "\u0190", 0x2109: "\u00B0F", 0x210A: "g",
+	0x210B: "H", 0x210C: "H", 0x210D: "H", 0x210E: "h", 0x210F: "\u0127", 0x2110: "I", 0x2111: "I",
+	0x2112: "L", 0x2113: "l", 0x2115: "N", 0x2116: "No", 0x2119: "P", 0x211A: "Q", 0x211B: "R",
+	0x211C: "R", 0x211D: "R", 0x2120: "SM", 0x2121: "TEL", 0x2122: "TM", 0x2124: "Z", 0x2128: "Z",
+	0x212C: "B", 0x212D: "C", 0x212F: "e", 0x2130: "E", 0x2131: "F", 0x2133: "M", 0x2134: "o",
+	0x2135: "\u05D0", 0x2136: "\u05D1", 0x2137: "\u05D2", 0x2138: "\u05D3", 0x2139: "i",
+	0x213B: "FAX", 0x213C: "\u03C0", 0x213D: "\u03B3", 0x213E: "\u0393", 0x213F: "\u03A0",
+	0x2140: "\u2211", 0x2145: "D", 0x2146: "d", 0x2147: "e", 0x2148: "i", 0x2149: "j",
+	0x2150: "1\u20447", 0x2151: "1\u20449", 0x2152: "1\u204410", 0x2153: "1\u20443",
+	0x2154: "2\u20443", 0x2155: "1\u20445", 0x2156: "2\u20445", 0x2157: "3\u20445",
+	0x2158: "4\u20445", 0x2159: "1\u20446", 0x215A: "5\u20446", 0x215B: "1\u20448",
+	0x215C: "3\u20448", 0x215D: "5\u20448", 0x215E: "7\u20448", 0x215F: "1\u2044", 0x2160: "I",
+	0x2161: "II", 0x2162: "III", 0x2163: "IV", 0x2164: "V", 0x2165: "VI", 0x2166: "VII",
+	0x2167: "VIII", 0x2168: "IX", 0x2169: "X", 0x216A: "XI", 0x216B: "XII", 0x216C: "L", 0x216D: "C",
+	0x216E: "D", 0x216F: "M", 0x2170: "i", 0x2171: "ii", 0x2172: "iii", 0x2173: "iv", 0x2174: "v",
+	0x2175: "vi", 0x2176: "vii", 0x2177: "viii", 0x2178: "ix", 0x2179: "x", 0x217A: "xi",
+	0x217B: "xii", 0x217C: "l", 0x217D: "c", 0x217E: "d", 0x217F: "m", 0x2189: "0\u20443",
+	0x2460: "1", 0x2461: "2", 0x2462: "3", 0x2463: "4", 0x2464: "5", 0x2465: "6", 0x2466: "7",
+	0x2467: "8", 0x2468: "9", 0x2469: "10", 0x246A: "11", 0x246B: "12", 0x246C: "13", 0x246D: "14",
+	0x246E: "15", 0x246F: "16", 0x2470: "17", 0x2471: "18", 0x2472: "19", 0x2473: "20", 0x2474: "(1)",
+	0x2475: "(2)", 0x2476: "(3)", 0x2477: "(4)", 0x2478: "(5)", 0x2479: "(6)", 0x247A: "(7)",
+	0x247B: "(8)", 0x247C: "(9)", 0x247D: "(10)", 0x247E: "(11)", 0x247F: "(12)", 0x2480: "(13)",
+	0x2481: "(14)", 0x2482: "(15)", 0x2483: "(16)", 0x2484: "(17)", 0x2485: "(18)", 0x2486: "(19)",
+	0x2487: "(20)", 0x2488: "1.", 0x2489: "2.", 0x248A: "3.", 0x248B: "4.", 0x248C: "5.",
+	0x248D: "6.", 0x248E: "7.", 0x248F: "8.", 0x2490: "9.", 0x2491: "10.", 0x2492: "11.",
+	0x2493: "12.", 0x2494: "13.", 0x2495: "14.", 0x2496: "15.", 0x2497: "16.", 0x2498: "17.",
+	0x2499: "18.", 0x249A: "19.", 0x249B: "20.", 0x249C: "(a)", 0x249D: "(b)", 0x249E: "(c)",
+	0x249F: "(d)", 0x24A0: "(e)", 0x24A1: "(f)", 0x24A2: "(g)", 0x24A3: "(h)", 0x24A4: "(i)",
+	0x24A5: "(j)", 0x24A6: "(k)", 0x24A7: "(l)", 0x24A8: "(m)", 0x24A9: "(n)", 0x24AA: "(o)",
+	0x24AB: "(p)", 0x24AC: "(q)", 0x24AD: "(r)", 0x24AE: "(s)", 0x24AF: "(t)", 0x24B0: "(u)",
+	0x24B1: "(v)", 0x24B2: "(w)", 0x24B3: "(x)", 0x24B4: "(y)", 0x24B5: "(z)", 0x24B6: "A",
+	0x24B7: "B", 0x24B8: "C", 0x24B9: "D", 0x24BA: "E", 0x24BB: "F", 0x24BC: "G", 0x24BD: "H",
+	0x24BE: "I", 0x24BF: "J", 0x24C0: "K", 0x24C1: "L", 0x24C2: "M", 0x24C3: "N", 0x24C4: "O",
+	0x24C5: "P", 0x24C6: "Q", 0x24C7: "R", 0x24C8: "S", 0x24C9: "T", 0x24CA: "U", 0x24CB: "V",
+	0x24CC: "W", 0x24CD: "X", 0x24CE: "Y", 0x24CF: "Z", 0x24D0: "a", 0x24D1: "b", 0x24D2: "c",
+	0x24D3: "d", 0x24D4: "e", 0x24D5: "f", 0x24D6: "g", 0x24D7: "h", 0x24D8: "i", 0x24D9: "j",
+	0x24DA: "k", 0x24DB: "l", 0x24DC: "m", 0x24DD: "n", 0x24DE: "o", 0x24DF: "p", 0x24E0: "q",
+	0x24E1: "r", 0x24E2: "s", 0x24E3: "t", 0x24E4: "u", 0x24E5: "v", 0x24E6: "w", 0x24E7: "x",
+	0x24E8: "y", 0x24E9: "z", 0x24EA: "0", 0x3000: " ", 0xFB00: "ff", 0xFB01: "fi", 0xFB02: "fl",
+	0xFB03: "ffi", 0xFB04: "ffl", 0xFB05: "st", 0xFB06: "st", 0xFF01: "!", 0xFF02: "\"", 0xFF03: "#",
+	0xFF04: "$", 0xFF05: "%", 0xFF06: "&", 0xFF07: "'", 0xFF08: "(", 0xFF09: ")", 0xFF0A: "*",
+	0xFF0B: "+", 0xFF0C: ",", 0xFF0D: "-", 0xFF0E: ".", 0xFF0F: "/", 0xFF10: "0", 0xFF11: "1",
+	0xFF12: "2", 0xFF13: "3", 0xFF14: "4", 0xFF15: "5", 0xFF16: "6", 0xFF17: "7", 0xFF18: "8",
+	0xFF19: "9", 0xFF1A: ":", 0xFF1B: ";", 0xFF1C: "<", 0xFF1D: "=", 0xFF1E: ">", 0xFF1F: "?",
+	0xFF20: "@", 0xFF21: "A", 0xFF22: "B", 0xFF23: "C", 0xFF24: "D", 0xFF25: "E", 0xFF26: "F",
+	0xFF27: "G", 0xFF28: "H", 0xFF29: "I", 0xFF2A: "J", 0xFF2B: "K", 0xFF2C: "L", 0xFF2D: "M",
+	0xFF2E: "N", 0xFF2F: "O", 0xFF30: "P", 0xFF31: "Q", 0xFF32: "R", 0xFF33: "S", 0xFF34: "T",
+	0xFF35: "U", 0xFF36: "V", 0xFF37: "W", 0xFF38: "X", 0xFF39: "Y", 0xFF3A: "Z", 0xFF3B: "[",
+	0xFF3C: "\\", 0xFF3D: "]", 0xFF3E: "^", 0xFF3F: "_", 0xFF40: "`", 0xFF41: "a", 0xFF42: "b",
+	0xFF43: "c", 0xFF44: "d", 0xFF45: "e", 0xFF46: "f", 0xFF47: "g", 0xFF48: "h", 0xFF49: "i",
+	0xFF4A: "j", 0xFF4B: "k", 0xFF4C: "l", 0xFF4D: "m", 0xFF4E: "n", 0xFF4F: "o", 0xFF50: "p",
+	0xFF51: "q", 0xFF52: "r", 0xFF53: "s", 0xFF54: "t", 0xFF55: "u", 0xFF56: "v", 0xFF57: "w",
+	0xFF58: "x", 0xFF59: "y", 0xFF5A: "z", 0xFF5B: "{", 0xFF5C: "|", 0xFF5D: "}", 0xFF5E: "~",
+	0xFF5F: "\u2985", 0xFF60: "\u2986", 0xFF61: "\u3002", 0xFF62: "\u300C", 0xFF63: "\u300D",
+	0xFF64: "\u3001", 0xFF65: "\u30FB", 0xFF66: "\u30F2", 0xFF67: "\u30A1", 0xFF68: "\u30A3",
+	0xFF69: "\u30A5", 0xFF6A: "\u30A7", 0xFF6B: "\u30A9", 0xFF6C: "\u30E3", 0xFF6D: "\u30E5",
+	0xFF6E: "\u30E7", 0xFF6F: "\u30C3", 0xFF70: "\u30FC", 0xFF71: "\u30A2", 0xFF72: "\u30A4",
+	0xFF73: "\u30A6", 0xFF74: "\u30A8", 0xFF75: "\u30AA", 0xFF76: "\u30AB", 0xFF77: "\u30AD",
+	0xFF78: "\u30AF", 0xFF79: "\u30B1", 0xFF7A: "\u30B3", 0xFF7B: "\u30B5", 0xFF7C: "\u30B7",
+	0xFF7D: "\u30B9", 0xFF7E: "\u30BB", 0xFF7F: "\u30BD", 0xFF80: "\u30BF", 0xFF81: "\u30C1",
+	0xFF82: "\u30C4", 0xFF83: "\u30C6", 0xFF84: "\u30C8", 0xFF85: "\u30CA", 0xFF86: "\u30CB",
+	0xFF87: "\u30CC", 0xFF88: "\u30CD", 0xFF89: "\u30CE", 0xFF8A: "\u30CF", 0xFF8B: "\u30D2",
+	0xFF8C: "\u30D5", 0xFF8D: "\u30D8", 0xFF8E: "\u30DB", 0xFF8F: "\u30DE", 0xFF90: "\u30DF",
+	0xFF91: "\u30E0", 0xFF92: "\u30E1", 0xFF93: "\u30E2", 0xFF94: "\u30E4", 0xFF95: "\u30E6",
+	0xFF96: "\u30E8", 0xFF97: "\u30E9", 0xFF98: "\u30EA", 0xFF99: "\u30EB", 0xFF9A: "\u30EC",
+	0xFF9B: "\u30ED", 0xFF9C: "\u30EF", 0xFF9D: "\u30F3", 0xFFA0: "\u1160", 0xFFA1: "\u1100",
+	0xFFA2: "\u1101", 0xFFA3: "\u11AA", 0xFFA4: "\u1102", 0xFFA5: "\u11AC", 0xFFA6: "\u11AD",
+	0xFFA7: "\u1103", 0xFFA8: "\u1104", 0xFFA9: "\u1105", 0xFFAA: "\u11B0", 0xFFAB: "\u11B1",
+	0xFFAC: "\u11B2", 0xFFAD: "\u11B3", 0xFFAE: "\u11B4", 0xFFAF: "\u11B5", 0xFFB0: "\u111A",
+	0xFFB1: "\u1106", 0xFFB2: "\u1107", 0xFFB3: "\u1108", 0xFFB4: "\u1121", 0xFFB5: "\u1109",
+	0xFFB6: "\u110A", 0xFFB7: "\u110B", 0xFFB8: "\u110C", 0xFFB9: "\u110D", 0xFFBA: "\u110E",
+	0xFFBB: "\u110F", 0xFFBC: "\u1110", 0xFFBD: "\u1111", 0xFFBE: "\u1112", 0xFFC2: "\u1161",
+	0xFFC3: "\u1162", 0xFFC4: "\u1163", 0xFFC5: "\u1164", 0xFFC6: "\u1165", 0xFFC7: "\u1166",
+	0xFFCA: "\u1167", 0xFFCB: "\u1168", 0xFFCC: "\u1169", 0xFFCD: "\u116A", 0xFFCE: "\u116B",
+	0xFFCF: "\u116C", 0xFFD2: "\u116D", 0xFFD3: "\u116E", 0xFFD4: "\u116F", 0xFFD5: "\u1170",
+	0xFFD6: "\u1171", 0xFFD7: "\u1172", 0xFFDA: "\u1173", 0xFFDB: "\u1174", 0xFFDC: "\u1175",
+	0xFFE0: "\u00A2", 0xFFE1: "\u00A3", 0xFFE2: "\u00AC", 0xFFE3: " ", 0xFFE4: "\u00A6",
+	0xFFE5: "\u00A5", 0xFFE6: "\u20A9", 0xFFE8: "\u2502", 0xFFE9: "\u2190", 0xFFEA: "\u2191",
+	0xFFEB: "\u2192", 0xFFEC: "\u2193", 0xFFED: "\u25A0", 0xFFEE: "\u25CB",
+}